@@ -0,0 +1,173 @@
+package mongoimport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Checkpoint records how many records of a file were inserted
+type Checkpoint struct {
+	File       string `bson:"file" json:"file"`
+	Collection string `bson:"collection" json:"collection"`
+	Size       int64  `bson:"size" json:"size"`
+	// Records is the number of records loaded from the file whose documents were all inserted
+	Records   int       `bson:"records" json:"records"`
+	Completed bool      `bson:"completed" json:"completed"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// CheckpointStore persists checkpoints so that interrupted imports can be resumed
+type CheckpointStore interface {
+	// Open is called with the client of the import before any file is imported
+	Open(client *mongo.Client) error
+	// Load returns the checkpoint of a file imported into the collection or nil if there is none
+	Load(collection string, file string) (*Checkpoint, error)
+	// Save replaces the checkpoint of a file and must be safe for concurrent use
+	Save(checkpoint Checkpoint) error
+	Close() error
+}
+
+// FileCheckpoints stores the checkpoints as JSON in a local file
+type FileCheckpoints struct {
+	Path        string
+	checkpoints map[string]Checkpoint
+	mux         sync.Mutex
+}
+
+// Open reads the existing checkpoints
+func (c *FileCheckpoints) Open(client *mongo.Client) error {
+	if c.Path == "" {
+		return errors.New("Missing checkpoint file path")
+	}
+	c.checkpoints = make(map[string]Checkpoint)
+	content, err := ioutil.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var checkpoints []Checkpoint
+	if err := json.Unmarshal(content, &checkpoints); err != nil {
+		return fmt.Errorf("Invalid checkpoint file %s: %v", c.Path, err)
+	}
+	for _, checkpoint := range checkpoints {
+		c.checkpoints[ledgerKey(checkpoint.Collection, checkpoint.File)] = checkpoint
+	}
+	return nil
+}
+
+// Load ...
+func (c *FileCheckpoints) Load(collection string, file string) (*Checkpoint, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if checkpoint, ok := c.checkpoints[ledgerKey(collection, file)]; ok {
+		return &checkpoint, nil
+	}
+	return nil, nil
+}
+
+// Save rewrites the checkpoint file, replacing it atomically so that it is not corrupted if the import is killed
+func (c *FileCheckpoints) Save(checkpoint Checkpoint) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.checkpoints[ledgerKey(checkpoint.Collection, checkpoint.File)] = checkpoint
+	var checkpoints []Checkpoint
+	for _, cp := range c.checkpoints {
+		checkpoints = append(checkpoints, cp)
+	}
+	content, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.Path), filepath.Base(c.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Failed to save checkpoint of %s to %s: %v", checkpoint.File, c.Path, err)
+	}
+	return nil
+}
+
+// Close ...
+func (c *FileCheckpoints) Close() error {
+	return nil
+}
+
+// CollectionCheckpoints stores the checkpoints in a MongoDB collection
+type CollectionCheckpoints struct {
+	DatabaseName string
+	Collection   string
+	collection   *mongo.Collection
+}
+
+// Open creates a unique index on the collection and file of the checkpoints
+func (c *CollectionCheckpoints) Open(client *mongo.Client) error {
+	if c.DatabaseName == "" || c.Collection == "" {
+		return errors.New("Missing checkpoint database or collection name")
+	}
+	c.collection = client.Database(c.DatabaseName).Collection(c.Collection)
+	_, err := c.collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "collection", Value: 1}, {Key: "file", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to create index for checkpoints %s:%s: %v", c.DatabaseName, c.Collection, err)
+	}
+	return nil
+}
+
+// Load ...
+func (c *CollectionCheckpoints) Load(collection string, file string) (*Checkpoint, error) {
+	var checkpoint Checkpoint
+	err := c.collection.FindOne(context.Background(), bson.M{"collection": collection, "file": file}).Decode(&checkpoint)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// Save ...
+func (c *CollectionCheckpoints) Save(checkpoint Checkpoint) error {
+	_, err := c.collection.ReplaceOne(context.Background(),
+		bson.M{"collection": checkpoint.Collection, "file": checkpoint.File},
+		checkpoint, options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("Failed to save checkpoint of %s to %s:%s: %v", checkpoint.File, c.DatabaseName, c.Collection, err)
+	}
+	return nil
+}
+
+// Close ...
+func (c *CollectionCheckpoints) Close() error {
+	return nil
+}
+
+// matches returns false if the file changed in size since the checkpoint was saved
+func (checkpoint *Checkpoint) matches(size int64) bool {
+	return checkpoint.Size < 0 || size < 0 || checkpoint.Size == size
+}
@@ -0,0 +1,82 @@
+package mongoimport
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/romnn/deepequal"
+)
+
+func TestFileCheckpoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoints.json")
+
+	store := &FileCheckpoints{Path: path}
+	if err := store.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint, err := store.Load("people", "a.csv"); err != nil || checkpoint != nil {
+		t.Errorf("Expected no checkpoint before the first save but got %v (%v)", checkpoint, err)
+	}
+
+	// Checkpoints are saved concurrently by the workers
+	var wg sync.WaitGroup
+	for _, file := range []string{"a.csv", "b.csv", "c.csv"} {
+		wg.Add(1)
+		go func(file string) {
+			defer wg.Done()
+			for records := 100; records <= 1000; records += 100 {
+				if err := store.Save(Checkpoint{File: file, Collection: "people", Size: 4096, Records: records}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(file)
+	}
+	wg.Wait()
+	if err := store.Save(Checkpoint{File: "c.csv", Collection: "people", Size: 4096, Records: 1200, Completed: true}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store = &FileCheckpoints{Path: path}
+	if err := store.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	expected := map[string]Checkpoint{
+		"a.csv": {File: "a.csv", Collection: "people", Size: 4096, Records: 1000},
+		"b.csv": {File: "b.csv", Collection: "people", Size: 4096, Records: 1000},
+		"c.csv": {File: "c.csv", Collection: "people", Size: 4096, Records: 1200, Completed: true},
+	}
+	for file, want := range expected {
+		checkpoint, err := store.Load("people", file)
+		if err != nil || checkpoint == nil {
+			t.Errorf("Missing checkpoint of %s (%v)", file, err)
+			continue
+		}
+		checkpoint.UpdatedAt = want.UpdatedAt
+		if equal, err := deepequal.DeepEqual(*checkpoint, want); !equal {
+			t.Errorf("Checkpoint of %s is %v but expected %v: %v", file, *checkpoint, want, err)
+		}
+	}
+}
+
+func TestCheckpointMatches(t *testing.T) {
+	checkpoint := &Checkpoint{Size: 4096, Records: 10}
+	if !checkpoint.matches(4096) {
+		t.Errorf("Expected the checkpoint to match a file of the same size")
+	}
+	if checkpoint.matches(100) {
+		t.Errorf("Expected the checkpoint to not match a file of a different size")
+	}
+	if !checkpoint.matches(-1) {
+		t.Errorf("Expected the checkpoint to match a stream of unknown size")
+	}
+}
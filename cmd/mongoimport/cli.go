@@ -10,6 +10,7 @@ import (
 	opt "github.com/romnn/configo"
 	"github.com/romnn/mongoimport"
 	"github.com/romnn/mongoimport/files"
+	"github.com/romnn/mongoimport/loaders"
 	"github.com/romnn/mongoimport/validation"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -18,10 +19,11 @@ import (
 func parseCollectionName(c *cli.Context, filename string, sanitize bool) (string, error) {
 	collection := c.String("collection")
 	if collection == "" {
-		if filename == "" {
+		if filename == "" || filename == "-" {
 			// if no filename is not set, we reading stdin
 			filename = "stdin"
 		}
+		filename = loaders.TrimCompressionExtension(filename)
 		base := filepath.Base(filename)
 		ext := filepath.Ext(filename)
 		collection = strings.TrimSuffix(base, ext)
@@ -37,14 +39,28 @@ func parseCollectionName(c *cli.Context, filename string, sanitize bool) (string
 }
 
 func parseMongoClient(cliCtx *cli.Context) *mongoimport.MongoConnection {
-	return &mongoimport.MongoConnection{
-		DatabaseName:     cliCtx.String("db-database"),
-		AuthDatabaseName: cliCtx.String("auth-db-database"),
-		User:             cliCtx.String("db-user"),
-		Password:         cliCtx.String("db-password"),
-		Host:             cliCtx.String("db-host"),
-		Port:             cliCtx.Uint("db-port"),
+	connection := &mongoimport.MongoConnection{
+		URI:                cliCtx.String("uri"),
+		DatabaseName:       cliCtx.String("db-database"),
+		AuthDatabaseName:   cliCtx.String("auth-db-database"),
+		User:               cliCtx.String("db-user"),
+		Password:           cliCtx.String("db-password"),
+		AuthMechanism:      cliCtx.String("db-auth-mechanism"),
+		TLS:                cliCtx.Bool("db-tls"),
+		TLSCAFile:          cliCtx.String("db-tls-ca-file"),
+		TLSCertificateFile: cliCtx.String("db-tls-cert-file"),
+		TLSKeyFile:         cliCtx.String("db-tls-key-file"),
+		TLSInsecure:        cliCtx.Bool("db-tls-insecure"),
+		ConnectTimeout:     cliCtx.Duration("db-timeout"),
 	}
+	// The default host and port must not replace the hosts of the connection URI
+	if connection.URI == "" || cliCtx.IsSet("db-host") {
+		connection.Host = cliCtx.String("db-host")
+	}
+	if connection.URI == "" || cliCtx.IsSet("db-port") {
+		connection.Port = cliCtx.Uint("db-port")
+	}
+	return connection
 }
 
 func setLogLevel(c *cli.Context) {
@@ -57,31 +73,134 @@ func setLogLevel(c *cli.Context) {
 }
 
 func getDatabaseParameters(c *cli.Context) (string, string, error) {
-	if c.String("db-database") == "" {
+	database := parseMongoClient(c).DefaultDatabase()
+	if database == "" {
 		return "", "", errors.New("Missing database name")
 	}
-	if c.String("collection") == "" {
-		return "", "", errors.New("Missing collection name")
+	input, named := collectionInput(c)
+	if c.String("collection") == "" && !named {
+		return "", "", errors.New("Missing collection name (required for several inputs, patterns, directories and URLs)")
+	}
+	collection, err := parseCollectionName(c, input, c.Bool("sanitize"))
+	if err != nil {
+		return "", "", err
+	}
+	return database, collection, nil
+}
+
+// collectionInput returns the input that names the collection if no collection is given.
+// Only stdin or a single local file name a collection, all other inputs are patterns, directories or URLs
+func collectionInput(c *cli.Context) (string, bool) {
+	if readsStdin(c) {
+		return c.Args().First(), true
+	}
+	fileArgs := c.Args().Slice()
+	if len(fileArgs) != 1 || c.Bool("glob") || c.Bool("watch") || c.Bool("archive") {
+		return "", false
+	}
+	if strings.HasPrefix(fileArgs[0], "s3://") || isURL(fileArgs[0]) {
+		return "", false
+	}
+	return fileArgs[0], true
+}
+
+func readsStdin(c *cli.Context) bool {
+	fileArgs := c.Args().Slice()
+	return len(fileArgs) < 1 || (len(fileArgs) == 1 && fileArgs[0] == "-")
+}
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return true
 	}
-	return c.String("db-database"), c.String("collection"), nil
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
-func getFileProviders(c *cli.Context) ([]files.FileProvider, error) {
-	var providers []files.FileProvider
+func getFileProviders(c *cli.Context) ([]files.StreamProvider, error) {
+	var providers []files.StreamProvider
 	fileArgs := c.Args().Slice()
-	if len(fileArgs) < 1 {
-		return providers, fmt.Errorf("Missing input source")
+	if readsStdin(c) {
+		if stdinIsTerminal() {
+			return providers, fmt.Errorf("Missing input source")
+		}
+		return []files.StreamProvider{files.Stdin()}, nil
 	}
-	if c.Bool("glob") {
+	if c.Bool("watch") {
+		for _, dir := range fileArgs {
+			providers = append(providers, &files.Watch{
+				Directory:  dir,
+				Recurse:    true,
+				Existing:   c.Bool("watch-existing"),
+				Settle:     c.Duration("settle"),
+				DoneMarker: c.String("done-marker"),
+				Handler:    isVisibleFile,
+			})
+		}
+	} else if c.Bool("follow") {
+		providers = append(providers, &files.Follow{Files: fileArgs})
+	} else if c.Bool("archive") {
+		for _, archive := range fileArgs {
+			providers = append(providers, &files.Archive{File: archive, Pattern: c.String("archive-pattern")})
+		}
+	} else if c.Bool("glob") {
 		for _, pattern := range fileArgs {
 			providers = append(providers, &files.Glob{Pattern: pattern})
 		}
 	} else {
-		providers = []files.FileProvider{&files.List{Files: fileArgs}}
+		// URLs are downloaded, all other arguments are local files
+		var urls, localFiles []string
+		for _, arg := range fileArgs {
+			if strings.HasPrefix(arg, "s3://") {
+				provider, err := getS3Provider(c, arg)
+				if err != nil {
+					return providers, err
+				}
+				providers = append(providers, provider)
+			} else if isURL(arg) {
+				urls = append(urls, arg)
+			} else {
+				localFiles = append(localFiles, arg)
+			}
+		}
+		if len(localFiles) > 0 {
+			providers = append(providers, &files.List{Files: localFiles})
+		}
+		if len(urls) > 0 {
+			providers = append(providers, &files.HTTP{URLs: urls})
+		}
 	}
 	return providers, nil
 }
 
+func getS3Provider(c *cli.Context, u string) (*files.S3, error) {
+	bucket, key, err := files.ParseS3URL(u)
+	if err != nil {
+		return nil, err
+	}
+	provider := &files.S3{
+		Bucket:         bucket,
+		Endpoint:       c.String("s3-endpoint"),
+		Region:         c.String("s3-region"),
+		ForcePathStyle: c.Bool("s3-path-style"),
+	}
+	if strings.ContainsAny(key, "*?[") {
+		provider.Pattern = key
+	} else {
+		provider.Prefix = key
+	}
+	return provider, nil
+}
+
+// isVisibleFile skips hidden files, which are often used for uploads in progress
+func isVisibleFile(path string, info os.FileInfo, err error) bool {
+	return !strings.HasPrefix(info.Name(), ".")
+}
+
+func isURL(arg string) bool {
+	return strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
@@ -90,11 +209,53 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
+func getLedger(c *cli.Context) mongoimport.Ledger {
+	if file := c.String("ledger-file"); file != "" {
+		return &mongoimport.FileLedger{Path: file}
+	}
+	if collection := c.String("ledger-collection"); collection != "" {
+		return &mongoimport.CollectionLedger{DatabaseName: parseMongoClient(c).DefaultDatabase(), Collection: collection}
+	}
+	return nil
+}
+
+func getCheckpoints(c *cli.Context) mongoimport.CheckpointStore {
+	if file := c.String("checkpoint-file"); file != "" {
+		return &mongoimport.FileCheckpoints{Path: file}
+	}
+	if collection := c.String("checkpoint-collection"); collection != "" {
+		return &mongoimport.CollectionCheckpoints{DatabaseName: parseMongoClient(c).DefaultDatabase(), Collection: collection}
+	}
+	return nil
+}
+
+func getRejects(c *cli.Context) mongoimport.RejectSink {
+	if file := c.String("reject-file"); file != "" {
+		return &mongoimport.FileRejects{Path: file}
+	}
+	if collection := c.String("reject-collection"); collection != "" {
+		return &mongoimport.CollectionRejects{DatabaseName: parseMongoClient(c).DefaultDatabase(), Collection: collection}
+	}
+	return nil
+}
+
 func parseImportOptions(c *cli.Context) (mongoimport.Options, error) {
 	database, collection, err := getDatabaseParameters(c)
 	if err != nil {
 		return mongoimport.Options{}, err
 	}
+	mode, err := mongoimport.ParseWriteMode(c.String("mode"))
+	if err != nil {
+		return mongoimport.Options{}, err
+	}
+	onDuplicate, err := mongoimport.ParseDuplicatePolicy(c.String("on-duplicate"))
+	if err != nil {
+		return mongoimport.Options{}, err
+	}
+	onAbort, err := mongoimport.ParseAbortPolicy(c.String("on-abort"))
+	if err != nil {
+		return mongoimport.Options{}, err
+	}
 	return mongoimport.Options{
 		DatabaseName:       database,
 		Collection:         collection,
@@ -105,6 +266,27 @@ func parseImportOptions(c *cli.Context) (mongoimport.Options, error) {
 		Sanitize:           opt.SetFlag(c.Bool("sanitize")),
 		FailOnErrors:       opt.SetFlag(c.Bool("fail-on-errors")),
 		CollectErrors:      opt.SetFlag(true),
+		RestoreMetadata:    opt.SetFlag(c.Bool("restore-metadata")),
+		Resume:             opt.SetFlag(c.Bool("resume")),
 		InsertionBatchSize: c.Int("insertion-batch-size"),
+		WriteMode:          mode,
+		UpsertFields:       c.StringSlice("upsert-fields"),
+		OnDuplicate:        onDuplicate,
+		Rejects:            getRejects(c),
+		OnAbort:            onAbort,
+		WriteOptions:       getWriteOptions(c),
 	}, nil
 }
+
+func getWriteOptions(c *cli.Context) mongoimport.WriteOptions {
+	writeOptions := mongoimport.WriteOptions{
+		W:                        c.String("write-concern"),
+		WTimeout:                 c.Duration("write-timeout"),
+		Ordered:                  opt.SetFlag(c.Bool("ordered")),
+		BypassDocumentValidation: opt.SetFlag(c.Bool("bypass-validation")),
+	}
+	if c.IsSet("journal") {
+		writeOptions.Journal = opt.SetFlag(c.Bool("journal"))
+	}
+	return writeOptions
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	opt "github.com/romnn/configo"
 	"github.com/romnn/mongoimport"
@@ -20,6 +24,13 @@ const Version = "0.1.12"
 
 var (
 	mongoConnectionOptions = []cli.Flag{
+		&cli.StringFlag{
+			Name:    "uri",
+			Aliases: []string{"db-uri"},
+			Value:   "",
+			EnvVars: []string{"MONGODB_URI"},
+			Usage:   "mongodb connection string (e.g. mongodb://host1,host2/db?replicaSet=rs0), the other db-* options take precedence",
+		},
 		&cli.StringFlag{
 			Name:    "db-host",
 			Aliases: []string{"host"},
@@ -62,6 +73,54 @@ var (
 			EnvVars: []string{"MONGODB_PASSWORD", "MONGO_PASS"},
 			Usage:   "mongodb database password",
 		},
+		&cli.StringFlag{
+			Name:    "db-auth-mechanism",
+			Aliases: []string{"auth-mechanism"},
+			Value:   "",
+			EnvVars: []string{"MONGODB_AUTH_MECHANISM"},
+			Usage:   "mongodb authentication mechanism (e.g. SCRAM-SHA-256 or MONGODB-X509 to authenticate with the client certificate)",
+		},
+		&cli.BoolFlag{
+			Name:    "db-tls",
+			Aliases: []string{"tls"},
+			Value:   false,
+			EnvVars: []string{"MONGODB_TLS"},
+			Usage:   "connect to mongodb using TLS",
+		},
+		&cli.StringFlag{
+			Name:    "db-tls-ca-file",
+			Aliases: []string{"tls-ca-file"},
+			Value:   "",
+			EnvVars: []string{"MONGODB_TLS_CA_FILE"},
+			Usage:   "PEM file with the certificate authorities used to verify the mongodb server (implies --db-tls)",
+		},
+		&cli.StringFlag{
+			Name:    "db-tls-cert-file",
+			Aliases: []string{"tls-cert-file"},
+			Value:   "",
+			EnvVars: []string{"MONGODB_TLS_CERT_FILE"},
+			Usage:   "PEM file with the client certificate and its key unless --db-tls-key-file is given (implies --db-tls)",
+		},
+		&cli.StringFlag{
+			Name:    "db-tls-key-file",
+			Aliases: []string{"tls-key-file"},
+			Value:   "",
+			EnvVars: []string{"MONGODB_TLS_KEY_FILE"},
+			Usage:   "PEM file with the key of the client certificate",
+		},
+		&cli.BoolFlag{
+			Name:    "db-tls-insecure",
+			Aliases: []string{"tls-insecure"},
+			Value:   false,
+			EnvVars: []string{"MONGODB_TLS_INSECURE"},
+			Usage:   "do not verify the certificate of the mongodb server (implies --db-tls)",
+		},
+		&cli.DurationFlag{
+			Name:    "db-timeout",
+			Value:   10 * time.Second,
+			EnvVars: []string{"MONGODB_TIMEOUT"},
+			Usage:   "time to connect to mongodb and verify that it is reachable and the authentication succeeds",
+		},
 	}
 
 	mongoImportOptions = []cli.Flag{
@@ -74,6 +133,24 @@ var (
 			Usage: "halt transaction on inconsistencies or errors",
 			Value: false,
 		},
+		&cli.IntFlag{
+			Name:    "max-errors",
+			Value:   0,
+			EnvVars: []string{"MAX_ERRORS"},
+			Usage:   "abort the import once more records failed (0 for no limit)",
+		},
+		&cli.Float64Flag{
+			Name:    "max-error-rate",
+			Value:   0,
+			EnvVars: []string{"MAX_ERROR_RATE"},
+			Usage:   "abort the import once more than this percentage of records failed (checked after 100 records, 0 for no limit)",
+		},
+		&cli.StringFlag{
+			Name:    "on-abort",
+			Value:   "flush",
+			EnvVars: []string{"ON_ABORT"},
+			Usage:   "insert (flush) or drop (discard) the loaded documents of the current batches when the import is aborted",
+		},
 		&cli.StringFlag{
 			Name:    "collection",
 			Aliases: []string{"c"},
@@ -100,6 +177,12 @@ var (
 			EnvVars: []string{"PARALELLISM", "THREADS"},
 			Usage:   "number of threads to use and files to keep open. Default (0) chooses the amount of logical CPU's available.",
 		},
+		&cli.IntFlag{
+			Name:    "inserters",
+			Value:   0,
+			EnvVars: []string{"INSERTERS"},
+			Usage:   "number of batches that are written concurrently. Default (0) uses the parallelism.",
+		},
 		&cli.IntFlag{
 			Name:    "insertion-batch-size",
 			Value:   100,
@@ -123,6 +206,161 @@ var (
 			EnvVars: []string{"GLOB"},
 			Usage:   "glob input files",
 		},
+		&cli.BoolFlag{
+			Name:    "archive",
+			Value:   false,
+			EnvVars: []string{"ARCHIVE"},
+			Usage:   "import the entries of zip or tar(.gz) archives",
+		},
+		&cli.StringFlag{
+			Name:    "archive-pattern",
+			Value:   "",
+			EnvVars: []string{"ARCHIVE_PATTERN"},
+			Usage:   "glob pattern for the archive entries to import (e.g. *.csv)",
+		},
+		&cli.BoolFlag{
+			Name:    "watch",
+			Value:   false,
+			EnvVars: []string{"WATCH"},
+			Usage:   "watch the input directories and import new files until interrupted",
+		},
+		&cli.BoolFlag{
+			Name:    "watch-existing",
+			Value:   true,
+			EnvVars: []string{"WATCH_EXISTING"},
+			Usage:   "also import the files that already exist in the watched directories",
+		},
+		&cli.DurationFlag{
+			Name:    "settle",
+			Value:   time.Second,
+			EnvVars: []string{"SETTLE"},
+			Usage:   "duration a watched file must not be modified before it is imported",
+		},
+		&cli.StringFlag{
+			Name:    "done-marker",
+			Value:   "",
+			EnvVars: []string{"DONE_MARKER"},
+			Usage:   "only import a watched file once a marker file with this suffix exists (e.g. .done)",
+		},
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"F"},
+			Value:   false,
+			EnvVars: []string{"FOLLOW"},
+			Usage:   "keep importing records appended to the input files like tail -F until interrupted",
+		},
+		&cli.DurationFlag{
+			Name:    "flush-interval",
+			Value:   time.Second,
+			EnvVars: []string{"FLUSH_INTERVAL"},
+			Usage:   "insert partial batches after no new records arrived for this duration when using --watch or --follow",
+		},
+		&cli.StringFlag{
+			Name:    "mode",
+			Value:   "insert",
+			EnvVars: []string{"MODE"},
+			Usage:   "write mode: insert, upsert, replace or merge (which sets the fields of existing documents)",
+		},
+		&cli.StringSliceFlag{
+			Name:    "upsert-fields",
+			EnvVars: []string{"UPSERT_FIELDS"},
+			Usage:   "comma separated fields that identify existing documents for the upsert, replace and merge modes",
+		},
+		&cli.StringFlag{
+			Name:    "on-duplicate",
+			Value:   "fail",
+			EnvVars: []string{"ON_DUPLICATE"},
+			Usage:   "handling of documents that violate a unique index: fail, skip, overwrite or collect",
+		},
+		&cli.StringFlag{
+			Name:    "write-concern",
+			Value:   "",
+			EnvVars: []string{"WRITE_CONCERN"},
+			Usage:   "number of members that must acknowledge writes, majority or a tag set (default of the server if empty)",
+		},
+		&cli.BoolFlag{
+			Name:    "journal",
+			EnvVars: []string{"JOURNAL"},
+			Usage:   "acknowledge writes only after they were written to the journal (default of the server if not set)",
+		},
+		&cli.DurationFlag{
+			Name:    "write-timeout",
+			Value:   0,
+			EnvVars: []string{"WRITE_TIMEOUT"},
+			Usage:   "time to wait for the write concern to be satisfied (0 for no limit)",
+		},
+		&cli.BoolFlag{
+			Name:    "ordered",
+			Value:   false,
+			EnvVars: []string{"ORDERED"},
+			Usage:   "write the documents of a batch in order and stop at the first failed write",
+		},
+		&cli.BoolFlag{
+			Name:    "bypass-validation",
+			Value:   false,
+			EnvVars: []string{"BYPASS_VALIDATION"},
+			Usage:   "skip the schema validation of the collections",
+		},
+		&cli.StringFlag{
+			Name:    "ledger-file",
+			Value:   "",
+			EnvVars: []string{"LEDGER_FILE"},
+			Usage:   "record imported files in this local file and skip unchanged files when importing again",
+		},
+		&cli.StringFlag{
+			Name:    "ledger-collection",
+			Value:   "",
+			EnvVars: []string{"LEDGER_COLLECTION"},
+			Usage:   "record imported files in this collection and skip unchanged files when importing again",
+		},
+		&cli.StringFlag{
+			Name:    "checkpoint-file",
+			Value:   "",
+			EnvVars: []string{"CHECKPOINT_FILE"},
+			Usage:   "save the progress of each file in this local file after every inserted batch",
+		},
+		&cli.StringFlag{
+			Name:    "checkpoint-collection",
+			Value:   "",
+			EnvVars: []string{"CHECKPOINT_COLLECTION"},
+			Usage:   "save the progress of each file in this collection after every inserted batch",
+		},
+		&cli.BoolFlag{
+			Name:    "resume",
+			Value:   false,
+			EnvVars: []string{"RESUME"},
+			Usage:   "skip completed files and continue partially imported files using the checkpoints",
+		},
+		&cli.StringFlag{
+			Name:    "reject-file",
+			Value:   "",
+			EnvVars: []string{"REJECT_FILE"},
+			Usage:   "append records that could not be imported as newline-delimited JSON to this local file",
+		},
+		&cli.StringFlag{
+			Name:    "reject-collection",
+			Value:   "",
+			EnvVars: []string{"REJECT_COLLECTION"},
+			Usage:   "insert records that could not be imported into this collection",
+		},
+		&cli.StringFlag{
+			Name:    "s3-endpoint",
+			Value:   "",
+			EnvVars: []string{"S3_ENDPOINT"},
+			Usage:   "custom endpoint for s3:// inputs (e.g. http://localhost:9000 for MinIO). Credentials are read from the AWS environment",
+		},
+		&cli.StringFlag{
+			Name:    "s3-region",
+			Value:   "",
+			EnvVars: []string{"AWS_REGION", "AWS_DEFAULT_REGION"},
+			Usage:   "region of the s3:// inputs",
+		},
+		&cli.BoolFlag{
+			Name:    "s3-path-style",
+			Value:   false,
+			EnvVars: []string{"S3_PATH_STYLE"},
+			Usage:   "use path style requests for s3:// inputs as required by MinIO",
+		},
 	}...)
 )
 
@@ -137,6 +375,10 @@ func startImport(c *cli.Context, ldr loaders.ImportLoader) error {
 		return err
 	}
 	options.Loader.SpecificLoader = ldr
+	checkpoints := getCheckpoints(c)
+	if c.Bool("resume") && checkpoints == nil {
+		return errors.New("Resuming requires --checkpoint-file or --checkpoint-collection")
+	}
 
 	var datasources []*mongoimport.Datasource
 	for _, provider := range providers {
@@ -146,13 +388,54 @@ func startImport(c *cli.Context, ldr loaders.ImportLoader) error {
 	}
 
 	i := mongoimport.Import{
-		Options:        options,
-		Sources:        datasources,
-		MaxParallelism: c.Int("parallelism"),
-		Connection:     parseMongoClient(c),
+		Options:           options,
+		Sources:           datasources,
+		MaxParallelism:    c.Int("parallelism"),
+		Inserters:         c.Int("inserters"),
+		Connection:        parseMongoClient(c),
+		Ledger:            getLedger(c),
+		Checkpoints:       checkpoints,
+		MaxTotalErrors:    c.Int("max-errors"),
+		MaxTotalErrorRate: c.Float64("max-error-rate"),
+	}
+
+	if c.Bool("watch") {
+		// Report each file as it is imported
+		i.PartialResultHook = func(partialResult mongoimport.PartialResult) {
+			for _, err := range partialResult.Errors {
+				log.Error(err)
+			}
+			log.Info(partialResult.Summary())
+		}
+	}
+	waiting := c.Bool("watch") || c.Bool("follow")
+	if waiting {
+		// Watched directories and followed files are imported until interrupted
+		i.FlushInterval = c.Duration("flush-interval")
 	}
 
-	result, err := i.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if waiting {
+			log.Info("Stopping to wait for new input")
+			for _, provider := range providers {
+				if stoppable, ok := provider.(interface{ Stop() }); ok {
+					stoppable.Stop()
+				}
+			}
+			<-signals
+		}
+		log.Info("Interrupted, inserting the current batches (interrupt again to exit immediately)")
+		cancel()
+		<-signals
+		log.Fatal("Interrupted")
+	}()
+
+	result, err := i.StartContext(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -165,7 +448,7 @@ func startImport(c *cli.Context, ldr loaders.ImportLoader) error {
 		}
 	}
 	log.Infof(result.Summary())
-	return nil
+	return result.Aborted
 }
 
 func main() {
@@ -176,16 +459,50 @@ func main() {
 		Commands: []*cli.Command{
 			{
 				Name:      "json",
-				ArgsUsage: "<json-files>",
+				ArgsUsage: "[<json-files>] (reads stdin if omitted)",
 				Usage:     "Import newline-delimited JSON objects into database",
-				Flags:     []cli.Flag{},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "path",
+						Usage: "import the elements at this path of a single JSON document instead of newline-delimited objects (e.g. data.items[*] or [*] for a top-level array)",
+					},
+					&cli.BoolFlag{
+						Name:  "extended-json",
+						Usage: "decode MongoDB Extended JSON v2 (e.g. $oid, $date, $numberDecimal) into BSON types",
+					},
+					&cli.BoolFlag{
+						Name:  "canonical",
+						Usage: "only accept canonical mode Extended JSON",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					jsonLoader := loaders.DefaultJSONLoader()
+					jsonLoader.Config = config.JSONReaderConfig{
+						Path:                  c.String("path"),
+						ExtendedJSON:          opt.SetFlag(c.Bool("extended-json")),
+						CanonicalExtendedJSON: opt.SetFlag(c.Bool("canonical")),
+					}
+					return startImport(c, jsonLoader)
+				},
+			},
+			{
+				Name:      "bson",
+				ArgsUsage: "[<bson-files>] (reads stdin if omitted)",
+				Usage:     "Import BSON files written by mongodump into database",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "restore-metadata",
+						Usage: "apply collection options and indexes from the .metadata.json file next to each .bson file",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					return fmt.Errorf("JSON is not yet implemented")
+					bsonLoader := loaders.DefaultBSONLoader()
+					return startImport(c, bsonLoader)
 				},
 			},
 			{
 				Name:      "xml",
-				ArgsUsage: "<xml-files>",
+				ArgsUsage: "[<xml-files>] (reads stdin if omitted)",
 				Usage:     "Import XML files into database",
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
@@ -256,7 +573,7 @@ func main() {
 			{
 				Name:      "csv",
 				Usage:     "Import CSV into database",
-				ArgsUsage: "<csv-files>",
+				ArgsUsage: "[<csv-files>] (reads stdin if omitted)",
 				Flags: []cli.Flag{
 					&cli.BoolFlag{
 						Name:  "excel",
@@ -0,0 +1,17 @@
+package config
+
+import (
+	opt "github.com/romnn/configo"
+)
+
+// JSONReaderConfig ...
+type JSONReaderConfig struct {
+	// Path selects the elements to import from a single JSON document (e.g. `data.items[*]` or `[*]`).
+	// Newline-delimited JSON is expected when no path is given
+	Path string
+	// ExtendedJSON decodes MongoDB Extended JSON v2 wrappers such as `$oid` or `$date` into BSON types.
+	// Both canonical and relaxed mode are accepted
+	ExtendedJSON *opt.Flag
+	// CanonicalExtendedJSON only accepts canonical mode Extended JSON
+	CanonicalExtendedJSON *opt.Flag
+}
@@ -2,50 +2,254 @@ package mongoimport
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/romnn/mongoimport/files"
+	"github.com/romnn/mongoimport/loaders"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 )
 
+// errNamespaceExists is returned by the server when creating a collection that already exists
+const errNamespaceExists = 48
+
+// defaultURI is used when neither a connection URI nor a host is given
+const defaultURI = "mongodb://localhost:27017"
+
 // MongoConnection ...
 type MongoConnection struct {
+	// URI is a MongoDB connection string (e.g. mongodb+srv://cluster.example.com/db?retryWrites=true).
+	// The other fields take precedence over the URI if they are set
+	URI              string
 	DatabaseName     string
 	AuthDatabaseName string
 	User             string
 	Password         string
 	Host             string
 	Port             uint
+	// AuthMechanism is e.g. SCRAM-SHA-256 or MONGODB-X509 for client certificate authentication
+	AuthMechanism string
+	// TLS enables TLS, which is implied by the other TLS options
+	TLS bool
+	// TLSCAFile contains the PEM encoded certificates of the authorities that signed the server certificates
+	TLSCAFile string
+	// TLSCertificateFile contains the PEM encoded client certificate and its key unless TLSKeyFile is set
+	TLSCertificateFile string
+	TLSKeyFile         string
+	// TLSInsecure skips the verification of the server certificates
+	TLSInsecure bool
+	// ConnectTimeout limits connecting and checking the server, which defaults to 10 seconds
+	ConnectTimeout time.Duration
+}
+
+// ClientOptions merges the connection URI with the other fields of the connection
+func (c *MongoConnection) ClientOptions() (*options.ClientOptions, error) {
+	uri := c.URI
+	if uri == "" {
+		uri = defaultURI
+	}
+	if c.User != "" {
+		// Authentication options of the URI are only valid together with the credentials
+		uri = withCredentials(uri, c.User, c.Password)
+	}
+	clientOptions := options.Client().ApplyURI(uri)
+	if c.Host != "" || c.Port != 0 {
+		host, port := c.Host, c.Port
+		if host == "" {
+			host = "localhost"
+		}
+		if port == 0 {
+			port = 27017
+		}
+		clientOptions.SetHosts([]string{net.JoinHostPort(host, strconv.Itoa(int(port)))})
+	}
+	if c.AuthMechanism != "" {
+		var auth options.Credential
+		if clientOptions.Auth != nil {
+			auth = *clientOptions.Auth
+		}
+		auth.AuthMechanism = c.AuthMechanism
+		clientOptions.SetAuth(auth)
+	}
+	if c.AuthDatabaseName != "" && clientOptions.Auth != nil {
+		clientOptions.Auth.AuthSource = c.AuthDatabaseName
+	}
+	if c.usesTLS() {
+		tlsConfig, err := c.tlsConfig(clientOptions.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+	if err := clientOptions.Validate(); err != nil {
+		// The URI is not included because it might contain a password
+		return nil, fmt.Errorf("Invalid connection options: %v", err)
+	}
+	return clientOptions, nil
+}
+
+// withCredentials puts the escaped user and password into a connection URI, replacing its credentials
+func withCredentials(uri, user, password string) string {
+	schemeEnd := strings.Index(uri, "://")
+	if schemeEnd < 0 {
+		return uri
+	}
+	start := schemeEnd + len("://")
+	end := len(uri)
+	if i := strings.IndexAny(uri[start:], "/?"); i >= 0 {
+		end = start + i
+	}
+	hosts := uri[start:end]
+	if at := strings.LastIndex(hosts, "@"); at >= 0 {
+		hosts = hosts[at+1:]
+	}
+	userinfo := url.User(user)
+	if password != "" {
+		userinfo = url.UserPassword(user, password)
+	}
+	return uri[:start] + userinfo.String() + "@" + hosts + uri[end:]
+}
+
+// DefaultDatabase returns the database name or the database of the connection URI
+func (c *MongoConnection) DefaultDatabase() string {
+	if c.DatabaseName != "" || c.URI == "" {
+		return c.DatabaseName
+	}
+	parsed, err := connstring.Parse(c.URI)
+	if err != nil {
+		return ""
+	}
+	return parsed.Database
 }
 
 // Client ...
 func (c *MongoConnection) Client() (*mongo.Client, error) {
-	var databaseAuth, databaseHost string
-	if c.User != "" && c.Password != "" {
-		databaseAuth = fmt.Sprintf("%s:%s@", c.User, c.Password)
+	return c.ClientContext(context.Background())
+}
+
+// ClientContext connects to the server and checks that it is reachable and the authentication succeeds.
+// Failures are returned as *ConnectionError
+func (c *MongoConnection) ClientContext(ctx context.Context) (*mongo.Client, error) {
+	clientOptions, err := c.ClientOptions()
+	if err != nil {
+		return nil, &ConnectionError{Stage: "options", Err: err}
 	}
-	databaseHost = fmt.Sprintf("%s:%d", c.Host, c.Port)
-	databaseConnectionURI := fmt.Sprintf("mongodb://%s%s/%s?connect=direct", databaseAuth, databaseHost, c.AuthDatabaseName)
-	client, err := mongo.NewClient(options.Client().ApplyURI(databaseConnectionURI))
+	client, err := mongo.NewClient(clientOptions)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create database client: %v (%s)", err, databaseConnectionURI)
+		return nil, &ConnectionError{Stage: "client", Err: err}
+	}
+	timeout := c.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
 	}
-	mctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	mctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	client.Connect(mctx)
+	if err := client.Connect(mctx); err != nil {
+		return nil, &ConnectionError{Stage: "connect", Err: err}
+	}
+	server, err := checkServer(mctx, client)
+	if err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	log.Infof("Connected to MongoDB %s (%s)", server.Version, server.Topology)
 	return client, nil
 }
 
-func insert(collection *mongo.Collection, batch []interface{}) error {
-	if len(batch) > 0 {
-		_, err := collection.InsertMany(context.Background(), batch)
+func emptyCollection(ctx context.Context, collection *mongo.Collection) error {
+	// Slower: _, err := collection.DeleteMany(ctx, bson.D{})
+	return collection.Drop(ctx)
+}
+
+// collectionMetadata is the content of the .metadata.json files written by mongodump
+type collectionMetadata struct {
+	Options bson.D   `bson:"options"`
+	Indexes []bson.D `bson:"indexes"`
+}
+
+// metadataFiles returns the candidate mongodump metadata files that belong to a .bson file
+func metadataFiles(file string) []string {
+	base := strings.TrimSuffix(loaders.TrimCompressionExtension(file), ".bson")
+	return []string{base + ".metadata.json", base + ".metadata.json.gz"}
+}
+
+// restoreMetadata creates the collection with the options and indexes from a mongodump metadata file
+func restoreMetadata(collection *mongo.Collection, file string) error {
+	f, err := (&files.LocalFile{Path: file}).Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader, err := loaders.Decompress(f)
+	if err != nil {
 		return err
 	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	var metadata collectionMetadata
+	if err := bson.UnmarshalExtJSON(data, false, &metadata); err != nil {
+		return fmt.Errorf("Failed to parse metadata file %s: %v", file, err)
+	}
+
+	create := bson.D{{Key: "create", Value: collection.Name()}}
+	create = append(create, metadata.Options...)
+	if err := collection.Database().RunCommand(context.Background(), create).Err(); err != nil {
+		var cmdErr mongo.CommandError
+		if !errors.As(err, &cmdErr) || cmdErr.Code != errNamespaceExists {
+			return fmt.Errorf("Failed to create collection %s: %v", collection.Name(), err)
+		}
+	}
+
+	var indexes bson.A
+	for _, index := range metadata.Indexes {
+		var spec bson.D
+		isDefault := false
+		for _, e := range index {
+			switch {
+			case e.Key == "ns":
+				// The namespace of the dumped collection does not apply
+			case e.Key == "name" && e.Value == "_id_":
+				isDefault = true
+			default:
+				spec = append(spec, e)
+			}
+		}
+		if !isDefault {
+			indexes = append(indexes, spec)
+		}
+	}
+	if len(indexes) < 1 {
+		return nil
+	}
+	createIndexes := bson.D{
+		{Key: "createIndexes", Value: collection.Name()},
+		{Key: "indexes", Value: indexes},
+	}
+	if err := collection.Database().RunCommand(context.Background(), createIndexes).Err(); err != nil {
+		return fmt.Errorf("Failed to create indexes for collection %s: %v", collection.Name(), err)
+	}
 	return nil
 }
 
-func emptyCollection(collection *mongo.Collection) error {
-	// Slower: _, err := collection.DeleteMany(context.Background(), bson.D{})
-	return collection.Drop(context.Background())
+func restoreMetadataIfPresent(collection *mongo.Collection, file string) error {
+	for _, metadata := range metadataFiles(file) {
+		if _, err := os.Stat(metadata); err == nil {
+			return restoreMetadata(collection, metadata)
+		}
+	}
+	return nil
 }
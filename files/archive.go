@@ -0,0 +1,320 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/romnn/mongoimport/loaders"
+)
+
+var zipMagic = []byte("PK\x03\x04")
+
+type archiveEntry struct {
+	name string
+	size int64
+	// index of the entry header in a tar archive
+	index int
+}
+
+// tarCursor is a reader positioned inside of a tar archive.
+// Tar archives cannot be seeked, so cursors are reused for entries further down the archive
+type tarCursor struct {
+	file         *os.File
+	decompressed io.ReadCloser
+	reader       *tar.Reader
+	next         int
+	busy         bool
+}
+
+func (cursor *tarCursor) close() {
+	cursor.decompressed.Close()
+	cursor.file.Close()
+}
+
+type tarEntryReader struct {
+	io.Reader
+	cursor   *tarCursor
+	provider *Archive
+}
+
+func (r *tarEntryReader) Close() error {
+	r.provider.releaseCursor(r.cursor)
+	return nil
+}
+
+// Archive provides the entries of a zip or (optionally compressed) tar archive
+type Archive struct {
+	File string
+	// Pattern optionally filters the entries. Patterns without a slash are matched against the base name of the entry
+	Pattern string
+
+	isZip      bool
+	entries    []archiveEntry
+	index      int
+	remaining  int
+	tarCursors []*tarCursor
+	mux        sync.Mutex
+}
+
+// Prepare ...
+func (provider *Archive) Prepare() error {
+	if provider.Pattern != "" {
+		if _, err := path.Match(provider.Pattern, ""); err != nil {
+			return err
+		}
+	}
+	isZip, err := isZipArchive(provider.File)
+	if err != nil {
+		return err
+	}
+	provider.isZip = isZip
+	if isZip {
+		err = provider.listZip()
+	} else {
+		err = provider.listTar()
+	}
+	provider.remaining = len(provider.entries)
+	return err
+}
+
+func isZipArchive(file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	header := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, header); err != nil && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.Equal(header, zipMagic), nil
+}
+
+func (provider *Archive) matches(name string) bool {
+	if provider.Pattern == "" {
+		return true
+	}
+	if !strings.Contains(provider.Pattern, "/") {
+		name = path.Base(name)
+	}
+	matched, _ := path.Match(provider.Pattern, name)
+	return matched
+}
+
+func (provider *Archive) listZip() error {
+	archive, err := zip.OpenReader(provider.File)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || !provider.matches(f.Name) {
+			continue
+		}
+		provider.entries = append(provider.entries, archiveEntry{name: f.Name, size: int64(f.UncompressedSize64)})
+	}
+	return nil
+}
+
+func (provider *Archive) openTar() (*tarCursor, error) {
+	file, err := os.Open(provider.File)
+	if err != nil {
+		return nil, err
+	}
+	decompressed, err := loaders.Decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &tarCursor{file: file, decompressed: decompressed, reader: tar.NewReader(decompressed)}, nil
+}
+
+func (provider *Archive) listTar() error {
+	cursor, err := provider.openTar()
+	if err != nil {
+		return err
+	}
+	defer cursor.close()
+	for {
+		header, err := cursor.reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to read tar archive %s: %v", provider.File, err)
+		}
+		if (header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA) && provider.matches(header.Name) {
+			provider.entries = append(provider.entries, archiveEntry{name: header.Name, size: header.Size, index: cursor.next})
+		}
+		cursor.next++
+	}
+}
+
+// FetchDirMetadata reports the uncompressed sizes of the entries
+func (provider *Archive) FetchDirMetadata(updateHandler MetadataUpdateHandler) {
+	var totalFiles, totalSize int64
+	var longestFilename string
+	for _, entry := range provider.entries {
+		totalFiles++
+		totalSize += entry.size
+		if filename := path.Base(entry.name); len(filename) > len(longestFilename) {
+			longestFilename = filename
+		}
+	}
+	updateHandler(totalFiles, totalSize, longestFilename)
+}
+
+// entryName names an entry by its path inside of the archive (e.g. `bundle.zip/data/a.csv`)
+func (provider *Archive) entryName(entry archiveEntry) string {
+	return filepath.Join(provider.File, filepath.FromSlash(entry.name))
+}
+
+// NextFile returns the name of the next entry, which is opened with Open
+func (provider *Archive) NextFile() (string, error) {
+	if provider.index >= len(provider.entries) {
+		return "", io.EOF
+	}
+	entry := provider.entries[provider.index]
+	provider.index++
+	return provider.entryName(entry), nil
+}
+
+// Open opens the entry with a name returned by NextFile
+func (provider *Archive) Open(file string) (io.ReadCloser, error) {
+	for _, entry := range provider.entries {
+		if provider.entryName(entry) == file {
+			return provider.open(entry)
+		}
+	}
+	return nil, fmt.Errorf("%s is not an entry of archive %s", file, provider.File)
+}
+
+// NextStream returns the next entry as a stream with its uncompressed size
+func (provider *Archive) NextStream() (Stream, error) {
+	if provider.index >= len(provider.entries) {
+		return nil, io.EOF
+	}
+	entry := provider.entries[provider.index]
+	provider.index++
+	return NewStream(provider.entryName(entry), entry.size, func() (io.ReadCloser, error) {
+		return provider.open(entry)
+	}), nil
+}
+
+func (provider *Archive) open(entry archiveEntry) (io.ReadCloser, error) {
+	provider.mux.Lock()
+	provider.remaining--
+	provider.mux.Unlock()
+	if provider.isZip {
+		return provider.openZipEntry(entry)
+	}
+	return provider.openTarEntry(entry)
+}
+
+type zipEntryReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (r *zipEntryReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.archive.Close()
+	return err
+}
+
+func (provider *Archive) openZipEntry(entry archiveEntry) (io.ReadCloser, error) {
+	// Each entry uses its own reader so entries can be read concurrently
+	archive, err := zip.OpenReader(provider.File)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range archive.File {
+		if f.Name == entry.name {
+			reader, err := f.Open()
+			if err != nil {
+				archive.Close()
+				return nil, err
+			}
+			return &zipEntryReader{ReadCloser: reader, archive: archive}, nil
+		}
+	}
+	archive.Close()
+	return nil, fmt.Errorf("%s is not an entry of archive %s", entry.name, provider.File)
+}
+
+func (provider *Archive) openTarEntry(entry archiveEntry) (io.ReadCloser, error) {
+	// Reuse the idle cursor that is closest to the entry
+	provider.mux.Lock()
+	var cursor *tarCursor
+	for _, c := range provider.tarCursors {
+		if !c.busy && c.next <= entry.index && (cursor == nil || c.next > cursor.next) {
+			cursor = c
+		}
+	}
+	if cursor == nil {
+		var err error
+		if cursor, err = provider.openTar(); err != nil {
+			provider.mux.Unlock()
+			return nil, err
+		}
+		provider.tarCursors = append(provider.tarCursors, cursor)
+	}
+	cursor.busy = true
+	provider.mux.Unlock()
+
+	for cursor.next <= entry.index {
+		header, err := cursor.reader.Next()
+		if err != nil {
+			provider.discardCursor(cursor)
+			if err == io.EOF {
+				return nil, fmt.Errorf("%s is not an entry of archive %s", entry.name, provider.File)
+			}
+			return nil, err
+		}
+		cursor.next++
+		if cursor.next > entry.index && header.Name != entry.name {
+			provider.discardCursor(cursor)
+			return nil, fmt.Errorf("Archive %s changed while reading %s", provider.File, entry.name)
+		}
+	}
+	return &tarEntryReader{Reader: cursor.reader, cursor: cursor, provider: provider}, nil
+}
+
+func (provider *Archive) releaseCursor(cursor *tarCursor) {
+	provider.mux.Lock()
+	defer provider.mux.Unlock()
+	cursor.busy = false
+	if provider.remaining > 0 {
+		return
+	}
+	// All entries were opened, so idle cursors will not be needed again
+	var busy []*tarCursor
+	for _, c := range provider.tarCursors {
+		if c.busy {
+			busy = append(busy, c)
+		} else {
+			c.close()
+		}
+	}
+	provider.tarCursors = busy
+}
+
+func (provider *Archive) discardCursor(cursor *tarCursor) {
+	provider.mux.Lock()
+	defer provider.mux.Unlock()
+	cursor.close()
+	for i, c := range provider.tarCursors {
+		if c == cursor {
+			provider.tarCursors = append(provider.tarCursors[:i], provider.tarCursors[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var archiveEntries = []struct {
+	name    string
+	content string
+}{
+	{"a.csv", "name\nSally Whittaker\n"},
+	{"data/b.csv", "name\nBelinda Jameson\nJeff Smith\n"},
+	{"data/readme.txt", "not imported"},
+	{"data/nested/c.csv", "name\nSandy Allen\n"},
+}
+
+func writeZip(t *testing.T, file string) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	writer := zip.NewWriter(f)
+	if _, err := writer.Create("data/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range archiveEntries {
+		w, err := writer.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entry.content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, file string) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	writer := tar.NewWriter(gz)
+	writer.WriteHeader(&tar.Header{Name: "data/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, entry := range archiveEntries {
+		writer.WriteHeader(&tar.Header{Name: entry.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(entry.content))})
+		writer.Write([]byte(entry.content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	zipFile := filepath.Join(dir, "bundle.zip")
+	tarFile := filepath.Join(dir, "bundle.tar.gz")
+	writeZip(t, zipFile)
+	writeTarGz(t, tarFile)
+
+	expected := map[string]string{}
+	var expectedSize int64
+	for _, entry := range archiveEntries {
+		if filepath.Ext(entry.name) == ".csv" {
+			expected[entry.name] = entry.content
+			expectedSize += int64(len(entry.content))
+		}
+	}
+
+	for _, archive := range []string{zipFile, tarFile} {
+		provider := &Archive{File: archive, Pattern: "*.csv"}
+		if err := provider.Prepare(); err != nil {
+			t.Fatalf("Failed to prepare %s: %s", archive, err.Error())
+		}
+
+		var totalFiles, totalSize int64
+		provider.FetchDirMetadata(func(interimFileCount int64, interimCombinedSize int64, interimLongestFilename string) {
+			totalFiles, totalSize = interimFileCount, interimCombinedSize
+		})
+		if totalFiles != int64(len(expected)) || totalSize != expectedSize {
+			t.Errorf("Metadata of %s reported %d files (%d bytes) but expected %d files (%d bytes)", archive, totalFiles, totalSize, len(expected), expectedSize)
+		}
+
+		var streams []Stream
+		for {
+			stream, err := provider.NextStream()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			streams = append(streams, stream)
+		}
+
+		// Open the entries concurrently and in reverse order like workers might do
+		contents := make(map[string]string)
+		var mux sync.Mutex
+		var wg sync.WaitGroup
+		for i := len(streams) - 1; i >= 0; i-- {
+			wg.Add(1)
+			go func(stream Stream) {
+				defer wg.Done()
+				name, size := stream.Name(), stream.Size()
+				reader, err := stream.Open()
+				if err != nil {
+					t.Errorf("Failed to open %s: %s", name, err.Error())
+					return
+				}
+				defer reader.Close()
+				content, err := ioutil.ReadAll(reader)
+				if err != nil {
+					t.Errorf("Failed to read %s: %s", name, err.Error())
+					return
+				}
+				if int64(len(content)) != size {
+					t.Errorf("Read %d bytes from %s but the reported size is %d", len(content), name, size)
+				}
+				entry, _ := filepath.Rel(archive, name)
+				mux.Lock()
+				contents[filepath.ToSlash(entry)] = string(content)
+				mux.Unlock()
+			}(streams[i])
+		}
+		wg.Wait()
+
+		if len(contents) != len(expected) {
+			t.Errorf("Read entries %v from %s but expected %v", contents, archive, expected)
+		}
+		for name, content := range expected {
+			if contents[name] != content {
+				t.Errorf("Entry %s of %s was %q but should be %q", name, archive, contents[name], content)
+			}
+		}
+	}
+}
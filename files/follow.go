@@ -0,0 +1,160 @@
+package files
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultFollowPollInterval = 250 * time.Millisecond
+
+// Follow provides files that keep growing, similar to `tail -F`.
+// Its streams wait for new data at the end of the file, continue with rotated files and restart truncated files until the provider is stopped
+type Follow struct {
+	Files []string
+	// PollInterval is the duration to wait for new data at the end of a file
+	PollInterval time.Duration
+
+	index    int
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Prepare ...
+func (provider *Follow) Prepare() error {
+	if provider.PollInterval <= 0 {
+		provider.PollInterval = defaultFollowPollInterval
+	}
+	provider.stop = make(chan struct{})
+	return nil
+}
+
+// Stop stops following, the streams end once they reached the end of their files
+func (provider *Follow) Stop() {
+	provider.stopOnce.Do(func() {
+		close(provider.stop)
+	})
+}
+
+// FetchDirMetadata reports the followed files without a size because they keep growing
+func (provider *Follow) FetchDirMetadata(updateHandler MetadataUpdateHandler) {
+	var longestFilename string
+	for _, file := range provider.Files {
+		if filename := filepath.Base(file); len(filename) > len(longestFilename) {
+			longestFilename = filename
+		}
+	}
+	updateHandler(int64(len(provider.Files)), 0, longestFilename)
+}
+
+// NextStream ...
+func (provider *Follow) NextStream() (Stream, error) {
+	if provider.index >= len(provider.Files) {
+		return nil, io.EOF
+	}
+	file := provider.Files[provider.index]
+	provider.index++
+	return NewStream(file, -1, func() (io.ReadCloser, error) {
+		return &followReader{path: file, provider: provider}, nil
+	}), nil
+}
+
+// followReader reads a file by name and only returns io.EOF after the provider was stopped
+type followReader struct {
+	path     string
+	provider *Follow
+	file     *os.File
+	info     os.FileInfo
+	offset   int64
+	waiting  bool
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		if r.file == nil {
+			if err := r.open(); err != nil {
+				if !r.waiting {
+					log.Warnf("Waiting for %s: %v", r.path, err)
+					r.waiting = true
+				}
+				if !r.wait() {
+					return 0, io.EOF
+				}
+				continue
+			}
+		}
+		n, err := r.file.Read(p)
+		r.offset += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if r.reopened() {
+			continue
+		}
+		if !r.wait() {
+			return 0, io.EOF
+		}
+	}
+}
+
+func (r *followReader) open() error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.info, r.offset, r.waiting = file, info, 0, false
+	return nil
+}
+
+// reopened checks if the file was rotated or truncated after reaching its end
+func (r *followReader) reopened() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		// The file was moved away and its replacement does not exist yet
+		return false
+	}
+	if !os.SameFile(info, r.info) {
+		log.Infof("%s was rotated", r.path)
+		r.file.Close()
+		r.file = nil
+		return true
+	}
+	if info.Size() < r.offset {
+		log.Infof("%s was truncated", r.path)
+		if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+			return false
+		}
+		r.offset = 0
+		return true
+	}
+	return false
+}
+
+// wait returns false if the provider was stopped
+func (r *followReader) wait() bool {
+	select {
+	case <-r.provider.stop:
+		return false
+	case <-time.After(r.provider.PollInterval):
+		return true
+	}
+}
+
+func (r *followReader) Close() error {
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
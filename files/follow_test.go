@@ -0,0 +1,107 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func expectFollowed(t *testing.T, chunks <-chan string, expected string) {
+	var read string
+	for read != expected {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				t.Fatalf("Stream ended after %q but expected %q", read, expected)
+			}
+			read += chunk
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out after reading %q but expected %q", read, expected)
+		}
+	}
+}
+
+func appendFollowed(t *testing.T, file string, content string) {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollowProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "follow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "app.log")
+	appendFollowed(t, file, "first\n")
+
+	provider := &Follow{Files: []string{file}, PollInterval: 10 * time.Millisecond}
+	if err := provider.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := provider.NextStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.NextStream(); err != io.EOF {
+		t.Errorf("Expected a single stream but got %v", err)
+	}
+	reader, err := stream.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	chunks := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 64)
+		for {
+			n, err := reader.Read(buf)
+			if n > 0 {
+				chunks <- string(buf[:n])
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	expectFollowed(t, chunks, "first\n")
+
+	// Appended data
+	appendFollowed(t, file, "second\n")
+	expectFollowed(t, chunks, "second\n")
+
+	// Truncation restarts at the beginning
+	if err := ioutil.WriteFile(file, []byte("third\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectFollowed(t, chunks, "third\n")
+
+	// Rotation continues with the new file
+	if err := os.Rename(file, file+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFollowed(t, file, "fourth\n")
+	expectFollowed(t, chunks, "fourth\n")
+
+	provider.Stop()
+	for range chunks {
+	}
+	if err := <-errs; err != io.EOF {
+		t.Errorf("Expected io.EOF after stopping but got %v", err)
+	}
+}
@@ -259,5 +259,9 @@ func (provider *Glob) NextFile() (string, error) {
 		return "", io.EOF
 	}
 	return file, nil
+}
 
+// NextStream ...
+func (provider *Glob) NextStream() (Stream, error) {
+	return nextLocalFile(provider)
 }
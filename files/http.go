@@ -0,0 +1,255 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHTTPMaxRetries = 3
+	defaultHTTPRetryDelay = time.Second
+)
+
+// HTTP provides the response bodies of HTTP(S) URLs
+type HTTP struct {
+	URLs []string
+	// Template is expanded for each of the values, e.g. `https://example.com/data/{{.}}.csv`
+	Template string
+	Values   []string
+	Client   *http.Client
+	Header   http.Header
+	// MaxRetries limits how often an interrupted download is resumed using range requests.
+	// Negative values disable resuming
+	MaxRetries int
+	RetryDelay time.Duration
+
+	urls  []string
+	index int
+}
+
+// Prepare ...
+func (provider *HTTP) Prepare() error {
+	if provider.Client == nil {
+		provider.Client = http.DefaultClient
+	}
+	if provider.MaxRetries == 0 {
+		provider.MaxRetries = defaultHTTPMaxRetries
+	}
+	if provider.RetryDelay == 0 {
+		provider.RetryDelay = defaultHTTPRetryDelay
+	}
+	provider.urls = append([]string{}, provider.URLs...)
+	if provider.Template != "" {
+		tmpl, err := template.New("url").Parse(provider.Template)
+		if err != nil {
+			return fmt.Errorf("Invalid URL template %s: %v", provider.Template, err)
+		}
+		for _, value := range provider.Values {
+			var expanded bytes.Buffer
+			if err := tmpl.Execute(&expanded, value); err != nil {
+				return err
+			}
+			provider.urls = append(provider.urls, expanded.String())
+		}
+	}
+	for _, u := range provider.urls {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return err
+		}
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			return fmt.Errorf("Unsupported URL %s: only http and https are supported", u)
+		}
+	}
+	return nil
+}
+
+func (provider *HTTP) request(method string, u string) (*http.Request, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range provider.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return req, nil
+}
+
+// FetchDirMetadata uses HEAD requests to obtain the content length of each URL
+func (provider *HTTP) FetchDirMetadata(updateHandler MetadataUpdateHandler) {
+	var totalFiles, totalSize int64
+	var longestFilename string
+	for _, u := range provider.urls {
+		totalFiles++
+		if req, err := provider.request(http.MethodHead, u); err == nil {
+			if resp, err := provider.Client.Do(req); err == nil {
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusOK && resp.ContentLength > 0 {
+					totalSize += resp.ContentLength
+				}
+			}
+		}
+		if filename := urlFilename(u); len(filename) > len(longestFilename) {
+			longestFilename = filename
+		}
+		updateHandler(totalFiles, totalSize, longestFilename)
+	}
+}
+
+func urlFilename(u string) string {
+	if parsed, err := url.Parse(u); err == nil {
+		return path.Base(parsed.Path)
+	}
+	return u
+}
+
+// NextStream ...
+func (provider *HTTP) NextStream() (Stream, error) {
+	if provider.index >= len(provider.urls) {
+		return nil, io.EOF
+	}
+	u := provider.urls[provider.index]
+	provider.index++
+	return &httpStream{url: u, size: -1, provider: provider}, nil
+}
+
+// httpStream learns its size from the response once it is opened
+type httpStream struct {
+	url      string
+	size     int64
+	provider *HTTP
+}
+
+func (s *httpStream) Name() string {
+	return s.url
+}
+
+func (s *httpStream) Size() int64 {
+	return s.size
+}
+
+func (s *httpStream) Open() (io.ReadCloser, error) {
+	req, err := s.provider.request(http.MethodGet, s.url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.provider.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", s.url, resp.Status)
+	}
+	s.size = resp.ContentLength
+	return &resumingReader{
+		stream:       s,
+		body:         resp.Body,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// resumingReader resumes interrupted downloads with range requests
+type resumingReader struct {
+	stream       *httpStream
+	body         io.ReadCloser
+	offset       int64
+	retries      int
+	etag         string
+	lastModified string
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+	if r.retries >= r.stream.provider.MaxRetries || r.stream.provider.MaxRetries < 0 {
+		return n, err
+	}
+	r.retries++
+	log.Warnf("Resuming download of %s at byte %d after error: %v", r.stream.url, r.offset, err)
+	time.Sleep(r.stream.provider.RetryDelay)
+	if resumeErr := r.resume(); resumeErr != nil {
+		return n, fmt.Errorf("%v (resuming failed: %v)", err, resumeErr)
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return r.Read(p)
+}
+
+func (r *resumingReader) resume() error {
+	r.body.Close()
+	if r.etag == "" && r.lastModified == "" {
+		return errors.New("the resource has neither an ETag nor a Last-Modified header to check that it did not change")
+	}
+	req, err := r.stream.provider.request(http.MethodGet, r.stream.url)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	// Make sure the remaining bytes belong to the same version of the resource
+	if r.etag != "" {
+		req.Header.Set("If-Range", r.etag)
+	} else if r.lastModified != "" {
+		req.Header.Set("If-Range", r.lastModified)
+	}
+	resp, err := r.stream.provider.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil || start != r.offset {
+			resp.Body.Close()
+			return fmt.Errorf("expected content from byte %d but got range %q", r.offset, resp.Header.Get("Content-Range"))
+		}
+		r.body = resp.Body
+		return nil
+	case http.StatusOK:
+		// The resource changed or the server does not support ranges. Only in the latter case can the bytes that were already read be skipped
+		if r.etag != "" && resp.Header.Get("ETag") != r.etag || r.etag == "" && resp.Header.Get("Last-Modified") != r.lastModified {
+			resp.Body.Close()
+			return errors.New("the resource changed during the download")
+		}
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, r.offset); err != nil {
+			resp.Body.Close()
+			return err
+		}
+		r.body = resp.Body
+		return nil
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("GET %s: %s", r.stream.url, resp.Status)
+	}
+}
+
+// contentRangeStart returns the first byte of a Content-Range header like `bytes 100-199/200`
+func contentRangeStart(contentRange string) (int64, error) {
+	var start, end int64
+	var total string
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &start, &end, &total); err != nil {
+		return -1, err
+	}
+	return start, nil
+}
+
+func (r *resumingReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,178 @@
+package files
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	content := map[string]string{
+		"/data/a.csv": strings.Repeat("name\nSally Whittaker\n", 500),
+		"/data/b.csv": "name\nBelinda Jameson\n",
+	}
+	modified := time.Now()
+	var interrupted int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := content[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && r.Header.Get("Range") == "" && r.URL.Path == "/data/a.csv" && atomic.CompareAndSwapInt32(&interrupted, 0, 1) {
+			// Interrupt the first download halfway through
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+			w.Write([]byte(data[:len(data)/2]))
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, r.URL.Path, modified, bytes.NewReader([]byte(data)))
+	}))
+	defer server.Close()
+
+	provider := &HTTP{
+		Template:   server.URL + "/data/{{.}}.csv",
+		Values:     []string{"a", "b"},
+		RetryDelay: time.Millisecond,
+	}
+	if err := provider.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	var totalFiles, totalSize int64
+	provider.FetchDirMetadata(func(interimFileCount int64, interimCombinedSize int64, interimLongestFilename string) {
+		totalFiles, totalSize = interimFileCount, interimCombinedSize
+	})
+	if expected := int64(len(content["/data/a.csv"]) + len(content["/data/b.csv"])); totalFiles != 2 || totalSize != expected {
+		t.Errorf("Metadata reported %d files (%d bytes) but expected 2 files (%d bytes)", totalFiles, totalSize, expected)
+	}
+
+	for _, path := range []string{"/data/a.csv", "/data/b.csv"} {
+		stream, err := provider.NextStream()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stream.Name() != server.URL+path {
+			t.Errorf("Expected stream %s but got %s", server.URL+path, stream.Name())
+		}
+		reader, err := stream.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %s", stream.Name(), err.Error())
+		}
+		if stream.Size() != int64(len(content[path])) {
+			t.Errorf("Size of %s was %d but expected %d", stream.Name(), stream.Size(), len(content[path]))
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %s", stream.Name(), err.Error())
+		}
+		if string(data) != content[path] {
+			t.Errorf("Read %d bytes from %s that do not match the %d bytes served", len(data), stream.Name(), len(content[path]))
+		}
+	}
+	if atomic.LoadInt32(&interrupted) != 1 {
+		t.Error("The download was not interrupted")
+	}
+	if _, err := provider.NextStream(); err != io.EOF {
+		t.Errorf("Expected EOF after all URLs but got %v", err)
+	}
+}
+
+func TestHTTPProviderNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	provider := &HTTP{URLs: []string{server.URL + "/missing.csv"}}
+	if err := provider.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := provider.NextStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Open(); err == nil {
+		t.Error("Expected an error when opening a missing URL")
+	}
+}
+
+func TestHTTPResumeChecksVersion(t *testing.T) {
+	data := strings.Repeat("name\nSally Whittaker\n", 500)
+	modified := time.Now().UTC().Format(http.TimeFormat)
+	changed := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	cases := map[string]struct {
+		headers map[string]string
+		resume  func(w http.ResponseWriter)
+		valid   bool
+	}{
+		"without validators": {
+			headers: map[string]string{},
+			resume:  func(w http.ResponseWriter) { w.Write([]byte(data)) },
+		},
+		"changed resource": {
+			headers: map[string]string{"Last-Modified": modified},
+			resume: func(w http.ResponseWriter) {
+				w.Header().Set("Last-Modified", changed)
+				w.Write([]byte(data))
+			},
+		},
+		"unexpected range": {
+			headers: map[string]string{"ETag": `"v1"`},
+			resume: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Range", "bytes 0-"+strconv.Itoa(len(data)-1)+"/"+strconv.Itoa(len(data)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(data))
+			},
+		},
+		"unchanged resource without ranges": {
+			headers: map[string]string{"Last-Modified": modified},
+			resume: func(w http.ResponseWriter) {
+				w.Header().Set("Last-Modified", modified)
+				w.Write([]byte(data))
+			},
+			valid: true,
+		},
+	}
+	for name, c := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") != "" {
+				c.resume(w)
+				return
+			}
+			// Interrupt the first download halfway through
+			for key, value := range c.headers {
+				w.Header().Set(key, value)
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write([]byte(data[:len(data)/2]))
+			panic(http.ErrAbortHandler)
+		}))
+		provider := &HTTP{URLs: []string{server.URL + "/a.csv"}, RetryDelay: time.Millisecond}
+		if err := provider.Prepare(); err != nil {
+			t.Fatal(err)
+		}
+		stream, err := provider.NextStream()
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := stream.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, err := ioutil.ReadAll(reader)
+		reader.Close()
+		server.Close()
+		if c.valid && (err != nil || string(read) != data) {
+			t.Errorf("Expected the download of the %s to be resumed but got %d bytes (%v)", name, len(read), err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("Expected the download of the %s not to be resumed", name)
+		}
+	}
+}
@@ -42,3 +42,8 @@ func (provider *List) NextFile() (string, error) {
 	provider.index++
 	return ret, nil
 }
+
+// NextStream ...
+func (provider *List) NextStream() (Stream, error) {
+	return nextLocalFile(provider)
+}
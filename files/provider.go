@@ -3,9 +3,39 @@ package files
 // MetadataUpdateHandler ...
 type MetadataUpdateHandler func(interimFileCount int64, interimCombinedSize int64, interimLongestFilename string)
 
-// FileProvider ...
+// FileProvider provides paths of files on the local filesystem
 type FileProvider interface {
 	Prepare() error
 	NextFile() (string, error)
 	FetchDirMetadata(updateHandler MetadataUpdateHandler)
 }
+
+// StreamProvider provides named streams that are not necessarily backed by the local filesystem
+type StreamProvider interface {
+	Prepare() error
+	NextStream() (Stream, error)
+	FetchDirMetadata(updateHandler MetadataUpdateHandler)
+}
+
+type pathStreams struct {
+	FileProvider
+}
+
+// NextStream ...
+func (provider pathStreams) NextStream() (Stream, error) {
+	return nextLocalFile(provider.FileProvider)
+}
+
+// nextLocalFile returns the next path of the provider as a stream of the local file
+func nextLocalFile(provider FileProvider) (Stream, error) {
+	file, err := provider.NextFile()
+	if err != nil {
+		return nil, err
+	}
+	return &LocalFile{Path: file}, nil
+}
+
+// PathStreams adapts a FileProvider to a StreamProvider that opens the provided paths from the local filesystem
+func PathStreams(provider FileProvider) StreamProvider {
+	return pathStreams{provider}
+}
@@ -0,0 +1,62 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pathProvider provides paths like custom file providers do
+type pathProvider struct {
+	paths []string
+}
+
+func (p *pathProvider) Prepare() error                         { return nil }
+func (p *pathProvider) FetchDirMetadata(MetadataUpdateHandler) {}
+func (p *pathProvider) NextFile() (string, error) {
+	if len(p.paths) == 0 {
+		return "", io.EOF
+	}
+	path := p.paths[0]
+	p.paths = p.paths[1:]
+	return path, nil
+}
+
+func TestPathStreams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "people.csv")
+	content := "name\nSally Whittaker\n"
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := PathStreams(&pathProvider{paths: []string{file}})
+	stream, err := provider.NextStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.Name() != file {
+		t.Errorf("Expected stream %s but got %s", file, stream.Name())
+	}
+	reader, err := stream.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != content {
+		t.Errorf("Read %q from %s but expected %q (%v)", data, file, content, err)
+	}
+	if stream.Size() != int64(len(content)) {
+		t.Errorf("Size of %s was %d but expected %d", file, stream.Size(), len(content))
+	}
+	if _, err := provider.NextStream(); err != io.EOF {
+		t.Errorf("Expected EOF after all paths but got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Reader provides a single stream of unknown size from an io.Reader such as stdin
+type Reader struct {
+	// Name describes the stream
+	Name     string
+	Reader   io.Reader
+	provided bool
+}
+
+// Stdin provides the standard input as a single stream
+func Stdin() *Reader {
+	return &Reader{Name: "stdin", Reader: os.Stdin}
+}
+
+// Prepare ...
+func (provider *Reader) Prepare() error {
+	if provider.Name == "" {
+		provider.Name = "stdin"
+	}
+	return nil
+}
+
+// FetchDirMetadata reports a single stream without a known size
+func (provider *Reader) FetchDirMetadata(updateHandler MetadataUpdateHandler) {
+	updateHandler(1, 0, provider.Name)
+}
+
+// NextStream ...
+func (provider *Reader) NextStream() (Stream, error) {
+	if provider.provided {
+		return nil, io.EOF
+	}
+	provider.provided = true
+	// The reader is owned by the caller and not closed after the import
+	return NewStream(provider.Name, -1, func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(provider.Reader), nil
+	}), nil
+}
@@ -0,0 +1,34 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestReaderProvider(t *testing.T) {
+	provider := &Reader{Reader: strings.NewReader("name\nSally Whittaker\n")}
+	if err := provider.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := provider.NextStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.Name() != "stdin" || stream.Size() >= 0 {
+		t.Errorf("Expected stdin of unknown size but got %s (%d bytes)", stream.Name(), stream.Size())
+	}
+	reader, err := stream.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil || string(content) != "name\nSally Whittaker\n" {
+		t.Errorf("Read %q (%v) from the stream", content, err)
+	}
+	reader.Close()
+	if _, err := provider.NextStream(); err != io.EOF {
+		t.Errorf("Expected EOF after the single stream but got %v", err)
+	}
+}
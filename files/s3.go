@@ -0,0 +1,170 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const defaultS3Region = "us-east-1"
+
+type s3Object struct {
+	key  string
+	size int64
+}
+
+// S3 provides the objects of a bucket in S3 compatible object storage such as MinIO
+type S3 struct {
+	Bucket string
+	Prefix string
+	// Pattern optionally filters the object keys. Patterns without a slash are matched against the base name of the key
+	Pattern string
+	// Endpoint overrides the AWS endpoint, e.g. `http://localhost:9000` for MinIO
+	Endpoint       string
+	Region         string
+	ForcePathStyle bool
+	// Static credentials are used if given, otherwise the default AWS credential chain applies
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client can be used instead of configuring the client using the fields above
+	Client s3iface.S3API
+
+	objects []s3Object
+	index   int
+}
+
+func (provider *S3) client() (s3iface.S3API, error) {
+	config := aws.NewConfig().WithS3ForcePathStyle(provider.ForcePathStyle)
+	region := provider.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	config = config.WithRegion(region)
+	if provider.Endpoint != "" {
+		config = config.WithEndpoint(provider.Endpoint)
+	}
+	if provider.AccessKeyID != "" {
+		config = config.WithCredentials(credentials.NewStaticCredentials(provider.AccessKeyID, provider.SecretAccessKey, provider.SessionToken))
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+	return s3.New(sess), nil
+}
+
+func (provider *S3) matches(key string) bool {
+	if provider.Pattern == "" {
+		return true
+	}
+	if !strings.Contains(provider.Pattern, "/") {
+		key = path.Base(key)
+	}
+	matched, _ := path.Match(provider.Pattern, key)
+	return matched
+}
+
+// listPrefix narrows the listing down to the literal part of the pattern
+func (provider *S3) listPrefix() string {
+	if provider.Prefix != "" || !strings.Contains(provider.Pattern, "/") {
+		return provider.Prefix
+	}
+	if i := strings.IndexAny(provider.Pattern, `*?[\`); i >= 0 {
+		return provider.Pattern[:i]
+	}
+	return provider.Pattern
+}
+
+// Prepare lists the matching objects
+func (provider *S3) Prepare() error {
+	if provider.Bucket == "" {
+		return fmt.Errorf("Missing S3 bucket name")
+	}
+	if provider.Pattern != "" {
+		if _, err := path.Match(provider.Pattern, ""); err != nil {
+			return err
+		}
+	}
+	if provider.Client == nil {
+		client, err := provider.client()
+		if err != nil {
+			return err
+		}
+		provider.Client = client
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(provider.Bucket),
+		Prefix: aws.String(provider.listPrefix()),
+	}
+	err := provider.Client.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+			if strings.HasSuffix(key, "/") || !provider.matches(key) {
+				continue
+			}
+			provider.objects = append(provider.objects, s3Object{key: key, size: aws.Int64Value(object.Size)})
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to list objects in bucket %s: %v", provider.Bucket, err)
+	}
+	return nil
+}
+
+// FetchDirMetadata reports the object sizes
+func (provider *S3) FetchDirMetadata(updateHandler MetadataUpdateHandler) {
+	var totalFiles, totalSize int64
+	var longestFilename string
+	for _, object := range provider.objects {
+		totalFiles++
+		totalSize += object.size
+		if filename := path.Base(object.key); len(filename) > len(longestFilename) {
+			longestFilename = filename
+		}
+	}
+	updateHandler(totalFiles, totalSize, longestFilename)
+}
+
+// NextStream returns the next object named by its URL (e.g. `s3://bucket/data/a.csv`)
+func (provider *S3) NextStream() (Stream, error) {
+	if provider.index >= len(provider.objects) {
+		return nil, io.EOF
+	}
+	object := provider.objects[provider.index]
+	provider.index++
+	name := fmt.Sprintf("s3://%s/%s", provider.Bucket, object.key)
+	return NewStream(name, object.size, func() (io.ReadCloser, error) {
+		output, err := provider.Client.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(provider.Bucket),
+			Key:    aws.String(object.key),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get %s: %v", name, err)
+		}
+		return output.Body, nil
+	}), nil
+}
+
+// ParseS3URL splits URLs like `s3://bucket/data/*.csv` into the bucket and a key pattern
+func ParseS3URL(u string) (string, string, error) {
+	if !strings.HasPrefix(u, "s3://") {
+		return "", "", fmt.Errorf("%s is not an s3:// URL", u)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u, "s3://"), "/", 2)
+	if parts[0] == "" {
+		return "", "", fmt.Errorf("Missing bucket name in %s", u)
+	}
+	if len(parts) < 2 {
+		return parts[0], "", nil
+	}
+	return parts[0], parts[1], nil
+}
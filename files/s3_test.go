@@ -0,0 +1,145 @@
+package files
+
+import (
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/romnn/deepequal"
+)
+
+type fakeS3Object struct {
+	Key  string
+	Size int64
+}
+
+type fakeS3ListResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []fakeS3Object `xml:"Contents"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+}
+
+// fakeS3 serves ListObjectsV2 with two keys per page and GetObject using path style requests
+func fakeS3(bucket string, objects map[string]string) http.Handler {
+	var keys []string
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		if parts[0] != bucket {
+			http.Error(w, "NoSuchBucket", http.StatusNotFound)
+			return
+		}
+		if len(parts) == 2 && parts[1] != "" {
+			content, ok := objects[parts[1]]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			io.WriteString(w, content)
+			return
+		}
+		prefix := r.URL.Query().Get("prefix")
+		start, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+		result := fakeS3ListResult{Name: bucket, Prefix: prefix}
+		var matching []string
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				matching = append(matching, key)
+			}
+		}
+		for i := start; i < len(matching) && i < start+2; i++ {
+			result.Contents = append(result.Contents, fakeS3Object{Key: matching[i], Size: int64(len(objects[matching[i]]))})
+		}
+		result.KeyCount = len(result.Contents)
+		if start+2 < len(matching) {
+			result.IsTruncated = true
+			result.NextContinuationToken = strconv.Itoa(start + 2)
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(result)
+	})
+}
+
+func TestS3Provider(t *testing.T) {
+	objects := map[string]string{
+		"exports/a.csv":        "name\nSally Whittaker\n",
+		"exports/b.csv":        "name\nBelinda Jameson\n",
+		"exports/c.txt":        "not imported",
+		"exports/nested/d.csv": "name\nJeff Smith\n",
+		"other/e.csv":          "name\nSandy Allen\n",
+	}
+	server := httptest.NewServer(fakeS3("bucket", objects))
+	defer server.Close()
+
+	provider := &S3{
+		Bucket:          "bucket",
+		Pattern:         "exports/*.csv",
+		Endpoint:        server.URL,
+		ForcePathStyle:  true,
+		AccessKeyID:     "access",
+		SecretAccessKey: "secret",
+	}
+	if err := provider.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	var totalFiles, totalSize int64
+	provider.FetchDirMetadata(func(interimFileCount int64, interimCombinedSize int64, interimLongestFilename string) {
+		totalFiles, totalSize = interimFileCount, interimCombinedSize
+	})
+	if expected := int64(len(objects["exports/a.csv"]) + len(objects["exports/b.csv"])); totalFiles != 2 || totalSize != expected {
+		t.Errorf("Metadata reported %d files (%d bytes) but expected 2 files (%d bytes)", totalFiles, totalSize, expected)
+	}
+
+	var names []string
+	for {
+		stream, err := provider.NextStream()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, stream.Name())
+		reader, err := stream.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(reader)
+		reader.Close()
+		key := strings.TrimPrefix(stream.Name(), "s3://bucket/")
+		if err != nil || string(content) != objects[key] {
+			t.Errorf("Read %q (%v) from %s but expected %q", content, err, stream.Name(), objects[key])
+		}
+		if stream.Size() != int64(len(objects[key])) {
+			t.Errorf("Size of %s was %d but expected %d", stream.Name(), stream.Size(), len(objects[key]))
+		}
+	}
+	expected := []string{"s3://bucket/exports/a.csv", "s3://bucket/exports/b.csv"}
+	if equal, err := deepequal.DeepEqual(names, expected); !equal {
+		t.Errorf("Provided %v but expected %v:\n%s", names, expected, err.Error())
+	}
+}
+
+func TestParseS3URL(t *testing.T) {
+	bucket, key, err := ParseS3URL("s3://bucket/exports/*.csv")
+	if err != nil || bucket != "bucket" || key != "exports/*.csv" {
+		t.Errorf("Parsed bucket %q and key %q (%v)", bucket, key, err)
+	}
+	if _, _, err := ParseS3URL("s3:///exports"); err == nil {
+		t.Error("Expected an error for a missing bucket")
+	}
+}
@@ -0,0 +1,65 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// Stream is a named input that is opened when it is imported
+type Stream interface {
+	// Name identifies the stream, e.g. by its path
+	Name() string
+	// Size returns the size in bytes or a negative value if it is not known
+	Size() int64
+	Open() (io.ReadCloser, error)
+}
+
+// LocalFile is a stream of a file on the local filesystem
+type LocalFile struct {
+	Path string
+}
+
+// Name ...
+func (f *LocalFile) Name() string {
+	return f.Path
+}
+
+// Size ...
+func (f *LocalFile) Size() int64 {
+	if info, err := os.Stat(f.Path); err == nil {
+		return info.Size()
+	}
+	return -1
+}
+
+// Open ...
+func (f *LocalFile) Open() (io.ReadCloser, error) {
+	if f.Path == "" {
+		return nil, errors.New("Got invalid empty file path")
+	}
+	return os.Open(f.Path)
+}
+
+type stream struct {
+	name string
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+func (s *stream) Name() string {
+	return s.name
+}
+
+func (s *stream) Size() int64 {
+	return s.size
+}
+
+func (s *stream) Open() (io.ReadCloser, error) {
+	return s.open()
+}
+
+// NewStream creates a stream that is opened using the open function
+func NewStream(name string, size int64, open func() (io.ReadCloser, error)) Stream {
+	return &stream{name: name, size: size, open: open}
+}
@@ -109,8 +109,11 @@ func (provider *Walker) fetchDirMetadata(dir *os.File, totalFiles int64, totalSi
 
 // NextFile ...
 func (provider *Walker) NextFile() (string, error) {
-	if provider.batchIndex >= len(provider.batch) {
-		// Load next batch
+	for provider.batchIndex >= len(provider.batch) {
+		// Load next batch, which might not contain any selected files
+		if len(provider.recFiles) < 1 {
+			return "", io.EOF
+		}
 		currentFile := provider.recFiles[len(provider.recFiles)-1]
 		batch, err := provider.nextBatch(currentFile)
 		if err != nil {
@@ -124,6 +127,11 @@ func (provider *Walker) NextFile() (string, error) {
 	return ret, nil
 }
 
+// NextStream ...
+func (provider *Walker) NextStream() (Stream, error) {
+	return nextLocalFile(provider)
+}
+
 // nextBatch ...
 func (provider *Walker) nextBatch(currentFile *os.File) ([]string, error) {
 	if provider.BatchSize < 0 {
@@ -133,7 +141,7 @@ func (provider *Walker) nextBatch(currentFile *os.File) ([]string, error) {
 		provider.BatchSize = defaulBatchSize
 	}
 	filenames, err := currentFile.Readdirnames(provider.BatchSize)
-	if err != nil {
+	if err != nil && err != io.EOF {
 		return nil, err
 	}
 	files := make([]string, len(filenames))
@@ -141,6 +149,7 @@ func (provider *Walker) nextBatch(currentFile *os.File) ([]string, error) {
 		files[i] = filepath.Join(currentFile.Name(), file)
 	}
 	if len(files) < 1 {
+		// The directory is exhausted, continue with its parent
 		currentFile.Close()
 		provider.recFiles = provider.recFiles[:len(provider.recFiles)-1]
 		if len(provider.recFiles) > 0 {
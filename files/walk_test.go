@@ -0,0 +1,69 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/romnn/deepequal"
+)
+
+func TestWalkerStreams(t *testing.T) {
+	dir, err := ioutil.TempDir("", "walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var expected []string
+	for _, file := range []string{"a.csv", "b.txt", "nested/c.csv", "nested/deeper/d.csv", "z.csv"} {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Ext(file) == ".csv" {
+			expected = append(expected, path)
+		}
+	}
+
+	provider := &Walker{
+		Directory: dir,
+		Recurse:   true,
+		// Small batches also yield batches without any selected files
+		BatchSize: 1,
+		Handler: func(path string, info os.FileInfo, err error) bool {
+			return filepath.Ext(path) == ".csv"
+		},
+	}
+	var streamProvider StreamProvider = provider
+	if err := streamProvider.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for {
+		stream, err := streamProvider.NextStream()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Each file contains its relative path
+		rel, _ := filepath.Rel(dir, stream.Name())
+		if size := stream.Size(); size != int64(len(rel)) {
+			t.Errorf("Size of %s was %d but expected %d", stream.Name(), size, len(rel))
+		}
+		found = append(found, stream.Name())
+	}
+	sort.Strings(expected)
+	sort.Strings(found)
+	if equal, err := deepequal.DeepEqual(found, expected); !equal {
+		t.Errorf("Walked %v but expected %v:\n%s", found, expected, err.Error())
+	}
+}
@@ -0,0 +1,241 @@
+package files
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWatchSettle        = time.Second
+	defaultWatchMetadataDelay = time.Second
+)
+
+// Watch provides files that appear in a directory until it is stopped.
+// Files are provided once they were not modified for the settle period or once their done marker appears
+type Watch struct {
+	Directory string
+	Handler   WalkerHandlerFunc
+	Recurse   bool
+	// Existing also provides the files that exist when the watch starts
+	Existing bool
+	// Settle is the duration a file must not be modified before it is provided
+	Settle time.Duration
+	// DoneMarker is the suffix of marker files that signal a file is complete (e.g. `.done` for `data.csv.done`).
+	// Files without a marker are not provided if set
+	DoneMarker string
+
+	watcher  *fsnotify.Watcher
+	pending  map[string]time.Time
+	provided map[string]bool
+	ready    chan string
+	stop     chan struct{}
+	stopOnce sync.Once
+	mux      sync.Mutex
+
+	totalFiles      int64
+	totalSize       int64
+	longestFilename string
+}
+
+// Prepare starts watching the directory
+func (provider *Watch) Prepare() error {
+	if provider.Handler == nil {
+		provider.Handler = func(path string, info os.FileInfo, err error) bool { return true }
+	}
+	if provider.Settle <= 0 {
+		provider.Settle = defaultWatchSettle
+	}
+	provider.pending = make(map[string]time.Time)
+	provider.provided = make(map[string]bool)
+	provider.ready = make(chan string, defaulBatchSize)
+	provider.stop = make(chan struct{})
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	provider.watcher = watcher
+	if _, err := openDirectory(provider.Directory); err != nil {
+		watcher.Close()
+		return err
+	}
+	if err := watcher.Add(provider.Directory); err != nil {
+		watcher.Close()
+		return err
+	}
+	go provider.run()
+	return nil
+}
+
+// Stop stops watching, NextStream returns io.EOF once all ready files were provided
+func (provider *Watch) Stop() {
+	provider.stopOnce.Do(func() {
+		close(provider.stop)
+	})
+}
+
+// watchDirectory adds the directory and its subdirectories to the watcher and considers the files they already contain
+func (provider *Watch) watchDirectory(dir string, existing bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Warn(err)
+			return nil
+		}
+		if info.IsDir() {
+			if path != dir && !provider.Recurse {
+				return filepath.SkipDir
+			}
+			return provider.watcher.Add(path)
+		}
+		if existing {
+			provider.consider(path, info.ModTime())
+		}
+		return nil
+	})
+}
+
+func (provider *Watch) isMarker(file string) bool {
+	return provider.DoneMarker != "" && strings.HasSuffix(file, provider.DoneMarker)
+}
+
+// consider marks a file as pending or ready depending on the completion strategy
+func (provider *Watch) consider(file string, modified time.Time) {
+	if provider.isMarker(file) {
+		provider.offer(strings.TrimSuffix(file, provider.DoneMarker))
+		return
+	}
+	if provider.DoneMarker != "" {
+		if _, err := os.Lstat(file + provider.DoneMarker); err == nil {
+			provider.offer(file)
+		}
+		return
+	}
+	provider.pending[file] = modified
+}
+
+func (provider *Watch) run() {
+	defer func() {
+		provider.watcher.Close()
+		close(provider.ready)
+	}()
+	interval := provider.Settle / 2
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// Existing files are considered here because providing them blocks until they are consumed
+	if err := provider.watchDirectory(provider.Directory, provider.Existing); err != nil {
+		log.Warnf("Failed to watch %s: %v", provider.Directory, err)
+	}
+	for {
+		select {
+		case <-provider.stop:
+			return
+		case event, ok := <-provider.watcher.Events:
+			if !ok {
+				return
+			}
+			provider.handleEvent(event)
+		case err, ok := <-provider.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("Watching %s: %v", provider.Directory, err)
+		case <-ticker.C:
+			provider.offerSettled()
+		}
+	}
+}
+
+func (provider *Watch) handleEvent(event fsnotify.Event) {
+	switch {
+	case event.Op&fsnotify.Create != 0:
+		info, err := os.Lstat(event.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			if provider.Recurse {
+				// Files might have been created before the watch was added
+				if err := provider.watchDirectory(event.Name, true); err != nil {
+					log.Warnf("Failed to watch %s: %v", event.Name, err)
+				}
+			}
+			return
+		}
+		provider.consider(event.Name, time.Now())
+	case event.Op&fsnotify.Write != 0:
+		if _, ok := provider.pending[event.Name]; ok {
+			provider.pending[event.Name] = time.Now()
+		}
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		delete(provider.pending, event.Name)
+	}
+}
+
+func (provider *Watch) offerSettled() {
+	now := time.Now()
+	for file, modified := range provider.pending {
+		if now.Sub(modified) >= provider.Settle {
+			delete(provider.pending, file)
+			provider.offer(file)
+		}
+	}
+}
+
+// offer provides a file unless it was already provided or is rejected by the handler
+func (provider *Watch) offer(file string) {
+	if provider.provided[file] {
+		return
+	}
+	info, err := os.Lstat(file)
+	if err != nil || info.IsDir() || provider.isMarker(file) || !provider.Handler(file, info, err) {
+		return
+	}
+	provider.provided[file] = true
+	provider.mux.Lock()
+	provider.totalFiles++
+	provider.totalSize += info.Size()
+	if filename := filepath.Base(file); len(filename) > len(provider.longestFilename) {
+		provider.longestFilename = filename
+	}
+	provider.mux.Unlock()
+	select {
+	case provider.ready <- file:
+	case <-provider.stop:
+	}
+}
+
+// FetchDirMetadata periodically reports the files provided so far until the watch is stopped
+func (provider *Watch) FetchDirMetadata(updateHandler MetadataUpdateHandler) {
+	ticker := time.NewTicker(defaultWatchMetadataDelay)
+	defer ticker.Stop()
+	for {
+		provider.mux.Lock()
+		totalFiles, totalSize, longestFilename := provider.totalFiles, provider.totalSize, provider.longestFilename
+		provider.mux.Unlock()
+		updateHandler(totalFiles, totalSize, longestFilename)
+		select {
+		case <-provider.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// NextStream blocks until the next file is ready or the watch is stopped
+func (provider *Watch) NextStream() (Stream, error) {
+	file, ok := <-provider.ready
+	if !ok {
+		return nil, io.EOF
+	}
+	return &LocalFile{Path: file}, nil
+}
@@ -0,0 +1,118 @@
+package files
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nextWatchedFile(t *testing.T, provider *Watch) string {
+	type next struct {
+		stream Stream
+		err    error
+	}
+	result := make(chan next, 1)
+	go func() {
+		stream, err := provider.NextStream()
+		result <- next{stream, err}
+	}()
+	select {
+	case n := <-result:
+		if n.err != nil {
+			t.Fatal(n.err)
+		}
+		return n.stream.Name()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a watched file")
+	}
+	return ""
+}
+
+func writeWatchedFile(t *testing.T, file string) {
+	if err := ioutil.WriteFile(file, []byte("name\nSally Whittaker\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "existing.csv")
+	writeWatchedFile(t, existing)
+	writeWatchedFile(t, filepath.Join(dir, ".hidden.csv"))
+
+	provider := &Watch{
+		Directory: dir,
+		Recurse:   true,
+		Existing:  true,
+		Settle:    50 * time.Millisecond,
+		Handler: func(path string, info os.FileInfo, err error) bool {
+			return filepath.Ext(path) == ".csv" && filepath.Base(path)[0] != '.'
+		},
+	}
+	if err := provider.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+
+	if file := nextWatchedFile(t, provider); file != existing {
+		t.Errorf("Got %s but expected the existing file %s", file, existing)
+	}
+
+	created := filepath.Join(dir, "created.csv")
+	writeWatchedFile(t, created)
+	if file := nextWatchedFile(t, provider); file != created {
+		t.Errorf("Got %s but expected the created file %s", file, created)
+	}
+
+	subdir := filepath.Join(dir, "nested")
+	if err := os.Mkdir(subdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(subdir, "nested.csv")
+	writeWatchedFile(t, nested)
+	if file := nextWatchedFile(t, provider); file != nested {
+		t.Errorf("Got %s but expected the nested file %s", file, nested)
+	}
+
+	provider.Stop()
+	if _, err := provider.NextStream(); err != io.EOF {
+		t.Errorf("Expected io.EOF after stopping but got %v", err)
+	}
+}
+
+func TestWatchProviderDoneMarker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	provider := &Watch{
+		Directory:  dir,
+		Settle:     50 * time.Millisecond,
+		DoneMarker: ".done",
+	}
+	if err := provider.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Stop()
+
+	incomplete := filepath.Join(dir, "incomplete.csv")
+	complete := filepath.Join(dir, "complete.csv")
+	writeWatchedFile(t, incomplete)
+	writeWatchedFile(t, complete)
+	// Give the settle period a chance to pass for files without markers
+	time.Sleep(200 * time.Millisecond)
+	writeWatchedFile(t, complete+".done")
+
+	if file := nextWatchedFile(t, provider); file != complete {
+		t.Errorf("Got %s but expected only the marked file %s", file, complete)
+	}
+}
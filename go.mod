@@ -4,12 +4,14 @@ go 1.13
 
 require (
 	github.com/JensRantil/go-csv v0.0.0-20200923162218-7ffda755f61b
-	github.com/aws/aws-sdk-go v1.37.3 // indirect
+	github.com/aws/aws-sdk-go v1.37.3
 	github.com/docker/go-connections v0.4.0
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/google/go-cmp v0.5.3 // indirect
 	github.com/gosuri/uilive v0.0.4 // indirect
 	github.com/gosuri/uiprogress v0.0.1
 	github.com/kennygrant/sanitize v1.2.4
+	github.com/klauspost/compress v1.11.7
 	github.com/mitchellh/mapstructure v1.4.1
 	github.com/prometheus/common v0.15.0
 	github.com/romnn/configo v0.1.2
@@ -18,6 +20,7 @@ require (
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/sirupsen/logrus v1.7.0
 	github.com/testcontainers/testcontainers-go v0.9.0
+	github.com/ulikunitz/xz v0.5.10
 	github.com/urfave/cli/v2 v2.3.0
 	go.mongodb.org/mongo-driver v1.4.6
 	google.golang.org/genproto v0.0.0-20210203152818-3206188e46ba // indirect
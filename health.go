@@ -0,0 +1,91 @@
+package mongoimport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// defaultConnectTimeout limits connecting and checking the server
+const defaultConnectTimeout = 10 * time.Second
+
+// errCommandNotFound is returned by servers that do not know a command
+const errCommandNotFound = 59
+
+// Topologies of MongoDB deployments
+const (
+	StandaloneTopology = "standalone"
+	ReplicaSetTopology = "replica set"
+	ShardedTopology    = "sharded cluster"
+)
+
+// ConnectionError is returned when the server is not reachable or the authentication failed
+type ConnectionError struct {
+	// Stage is the failed step of the connection setup (options, client, connect, ping, hello or buildInfo)
+	Stage string
+	Err   error
+}
+
+// Error ...
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("Failed to connect to MongoDB (%s): %v", e.Stage, e.Err)
+}
+
+// Unwrap ...
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// ServerInfo describes the MongoDB deployment that was connected to
+type ServerInfo struct {
+	Version  string
+	Topology string
+	// SetName is the name of the replica set
+	SetName string
+	Hosts   []string
+}
+
+type helloResponse struct {
+	SetName string   `bson:"setName"`
+	Msg     string   `bson:"msg"`
+	Hosts   []string `bson:"hosts"`
+}
+
+// checkServer pings the primary, which requires a successful authentication, and describes the deployment
+func checkServer(ctx context.Context, client *mongo.Client) (*ServerInfo, error) {
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		return nil, &ConnectionError{Stage: "ping", Err: err}
+	}
+	admin := client.Database("admin")
+	var hello helloResponse
+	err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.Code == errCommandNotFound {
+		// Servers before 4.4.2 only know the legacy command
+		err = admin.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+	}
+	if err != nil {
+		return nil, &ConnectionError{Stage: "hello", Err: err}
+	}
+	var build struct {
+		Version string `bson:"version"`
+	}
+	if err := admin.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&build); err != nil {
+		return nil, &ConnectionError{Stage: "buildInfo", Err: err}
+	}
+	return hello.serverInfo(build.Version), nil
+}
+
+func (hello helloResponse) serverInfo(version string) *ServerInfo {
+	info := &ServerInfo{Version: version, Topology: StandaloneTopology, SetName: hello.SetName, Hosts: hello.Hosts}
+	switch {
+	case hello.Msg == "isdbgrid":
+		info.Topology = ShardedTopology
+	case hello.SetName != "":
+		info.Topology = ReplicaSetTopology
+	}
+	return info
+}
@@ -1,6 +1,7 @@
 package mongoimport
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"sync"
@@ -15,33 +16,73 @@ import (
 // Import ...
 type Import struct {
 	Options
-	Connection                  *MongoConnection
-	Sources                     []*Datasource
-	MaxParallelism              int
+	Connection     *MongoConnection
+	Sources        []*Datasource
+	MaxParallelism int
+	// Inserters is the number of concurrent bulk writes of all files, which defaults to MaxParallelism
+	Inserters                   int
+	PartialResultHook           PartialResultHook
+	Ledger                      Ledger
+	Checkpoints                 CheckpointStore
+	MaxTotalErrors              int
+	MaxTotalErrorRate           float64
 	dbClient                    *mongo.Client
 	longestCollectionName       string
 	longestDescription          string
 	sources                     []*Datasource
 	newProgressBarMux           sync.Mutex
 	updateLongestDescriptionMux sync.Mutex
+	ctx                         context.Context
+	budget                      *errorBudget
+	aborted                     chan struct{}
+	abortOnce                   sync.Once
+	abortReason                 error
+	// abortDeadline ends writing the queued batches of an aborted import
+	abortDeadline time.Time
+	inserts       chan insertBatch
 }
 
 // Start ...
 func (i *Import) Start() (ImportResult, error) {
+	return i.StartContext(context.Background())
+}
+
+// StartContext imports all sources until they are done or ctx is cancelled.
+// Cancelling stops producing and loading files like exceeding an error limit and the result reports the import as aborted
+func (i *Import) StartContext(ctx context.Context) (ImportResult, error) {
 	var preWg, workerWg sync.WaitGroup
 	var result ImportResult
 	var err error
 
+	i.ctx = ctx
+	i.aborted = make(chan struct{})
+
 	if i.MaxParallelism < 1 {
 		i.MaxParallelism = runtime.NumCPU()
 	}
 	runtime.GOMAXPROCS(i.MaxParallelism)
+	if i.Inserters < 1 {
+		i.Inserters = i.MaxParallelism
+	}
 
-	i.dbClient, err = i.Connection.Client()
+	i.dbClient, err = i.Connection.ClientContext(ctx)
 	if err != nil {
 		return result, err
 	}
 
+	if i.Ledger != nil {
+		if err := i.Ledger.Open(i.dbClient); err != nil {
+			return result, err
+		}
+		defer i.Ledger.Close()
+	}
+	if i.Checkpoints != nil {
+		if err := i.Checkpoints.Open(i.dbClient); err != nil {
+			return result, err
+		}
+		defer i.Checkpoints.Close()
+	}
+
 	for _, source := range i.Sources {
 		if !source.Disabled {
 			i.sources = append(i.sources, source)
@@ -60,19 +101,63 @@ func (i *Import) Start() (ImportResult, error) {
 			i.longestDescription = source.Description
 		}
 
+		if err := source.validateWriteMode(); err != nil {
+			return result, err
+		}
+		if err := source.validateWriteOptions(); err != nil {
+			return result, err
+		}
+		if err := source.validateErrorLimits(); err != nil {
+			return result, err
+		}
+		source.prepareErrorBudget()
 		source.prepareHooks()
 		source.bars = make(map[string]*uiprogress.Bar)
 		source.owner = i
 	}
 
+	// Sources share the reject sink of the import, which is opened only once
+	opened := make(map[RejectSink]bool)
+	for _, source := range i.sources {
+		sink := source.Options.Rejects
+		if sink == nil || opened[sink] {
+			continue
+		}
+		if err := sink.Open(i.dbClient); err != nil {
+			return result, err
+		}
+		defer sink.Close()
+		opened[sink] = true
+	}
+
+	// Cancellation aborts the import once the sources are prepared
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			i.abortIfCancelled(ctx)
+		case <-finished:
+		}
+	}()
+
 	if err := i.emptyCollections(&preWg); err != nil {
 		return result, err
 	}
 
 	// Wait for preprocessing to complete before starting workers and producers
 	preWg.Wait()
+	if i.abortIfCancelled(ctx) {
+		// Nothing is imported if the import was cancelled while preparing
+		result.TotalSources = len(i.sources)
+		result.Aborted = i.abortReason
+		return result, nil
+	}
+	i.budget = newErrorBudget("", i.MaxTotalErrors, i.MaxTotalErrorRate)
 	jobChan := make(chan ImportJob, 2*i.MaxParallelism)
 	resultsChan := make(chan PartialResult)
+	// Loaders block once all inserters are busy and the queued batches were not taken yet
+	i.inserts = make(chan insertBatch, i.Inserters)
 	producerDoneChan := make(chan bool)
 
 	start := time.Now()
@@ -86,30 +171,57 @@ func (i *Import) Start() (ImportResult, error) {
 
 	// Collect all partial results
 	for partial := range resultsChan {
-		partial.Source.doneFileCount++
-		partial.Source.updateDescription()
-		// Add to source result
-		srcResult := &partial.Source.result
-		srcResult.Succeeded += partial.Succeeded
-		srcResult.Failed += partial.Failed
-		srcResult.Collection = partial.Source.Collection
-		srcResult.TotalFiles++
-		if opt.Enabled(partial.Source.Options.IndividualProgress) || opt.Enabled(i.Options.CollectErrors) {
-			srcResult.PartialResults = append(srcResult.PartialResults, partial)
-		}
-		// Add to total result
-		result.PartialResults = append(result.PartialResults, *srcResult)
-		result.Succeeded += partial.Succeeded
-		result.Failed += partial.Failed
-		result.TotalFiles++
+		i.collect(&result, partial)
 	}
 
+	for _, source := range i.sources {
+		result.Skipped += source.result.Skipped
+	}
 	result.TotalSources = len(i.sources)
+	// The cancellation might not have been noticed yet
+	i.abortIfCancelled(ctx)
+	result.Aborted = i.abortReason
 	uiprogress.Stop()
 	result.Elapsed = time.Since(start)
 	return result, nil
 }
 
+// collect adds the result of an imported file to the results of its source and the import
+func (i *Import) collect(result *ImportResult, partial PartialResult) {
+	partial.Source.doneFileCount++
+	partial.Source.updateDescription()
+	// The producers of the source add the results of files that were not imported concurrently
+	partial.Source.resultMux.Lock()
+	// Add to source result
+	srcResult := &partial.Source.result
+	srcResult.Succeeded += partial.Succeeded
+	srcResult.Failed += partial.Failed
+	srcResult.Inserted += partial.Inserted
+	srcResult.Matched += partial.Matched
+	srcResult.Modified += partial.Modified
+	srcResult.Upserted += partial.Upserted
+	srcResult.Duplicates += partial.Duplicates
+	srcResult.Collection = partial.Source.Collection
+	srcResult.TotalFiles++
+	if opt.Enabled(partial.Source.Options.IndividualProgress) || opt.Enabled(i.Options.CollectErrors) {
+		srcResult.PartialResults = append(srcResult.PartialResults, partial)
+	}
+	// Add to total result
+	result.PartialResults = append(result.PartialResults, *srcResult)
+	partial.Source.resultMux.Unlock()
+	result.Succeeded += partial.Succeeded
+	result.Failed += partial.Failed
+	result.Inserted += partial.Inserted
+	result.Matched += partial.Matched
+	result.Modified += partial.Modified
+	result.Upserted += partial.Upserted
+	result.Duplicates += partial.Duplicates
+	result.TotalFiles++
+	if i.PartialResultHook != nil {
+		i.PartialResultHook(partial)
+	}
+}
+
 func (i *Import) emptyCollections(preWg *sync.WaitGroup) error {
 	// Eventually empty collections
 	needEmpty := make(map[string][]string)
@@ -132,7 +244,7 @@ func (i *Import) emptyCollections(preWg *sync.WaitGroup) error {
 				defer preWg.Done()
 				log.Infof("Deleting all documents in %s:%s", db, collectionName)
 				collection := i.dbClient.Database(db).Collection(collectionName)
-				err := emptyCollection(collection)
+				err := emptyCollection(i.ctx, collection)
 				if err != nil {
 					log.Warnf("Failed to delete all documents in collection %s:%s: %s", db, collectionName, err.Error())
 				} else {
@@ -0,0 +1,219 @@
+package mongoimport
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/romnn/mongoimport/files"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LedgerEntry describes a file that was imported successfully
+type LedgerEntry struct {
+	File       string    `bson:"file" json:"file"`
+	Collection string    `bson:"collection" json:"collection"`
+	Size       int64     `bson:"size" json:"size"`
+	ModTime    time.Time `bson:"mtime" json:"mtime"`
+	Hash       string    `bson:"hash" json:"hash"`
+	ImportedAt time.Time `bson:"importedAt" json:"importedAt"`
+}
+
+// Ledger records imported files so that unchanged files are skipped when an import is repeated
+type Ledger interface {
+	// Open is called with the client of the import before any file is imported
+	Open(client *mongo.Client) error
+	// Lookup returns the last entry of a file imported into the collection or nil if there is none
+	Lookup(collection string, file string) (*LedgerEntry, error)
+	// Record adds or replaces the entry of a file and must be safe for concurrent use
+	Record(entry LedgerEntry) error
+	Close() error
+}
+
+// FileLedger stores the ledger as newline-delimited JSON in a local file
+type FileLedger struct {
+	Path    string
+	entries map[string]LedgerEntry
+	file    *os.File
+	mux     sync.Mutex
+}
+
+func ledgerKey(collection string, file string) string {
+	return collection + "\x00" + file
+}
+
+// Open reads the existing entries
+func (l *FileLedger) Open(client *mongo.Client) error {
+	if l.Path == "" {
+		return errors.New("Missing ledger file path")
+	}
+	l.entries = make(map[string]LedgerEntry)
+	file, err := os.OpenFile(l.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry LedgerEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			file.Close()
+			return fmt.Errorf("Invalid ledger entry in %s at line %d: %v", l.Path, line, err)
+		}
+		// Later entries replace earlier entries of the same file
+		l.entries[ledgerKey(entry.Collection, entry.File)] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	return nil
+}
+
+// Lookup ...
+func (l *FileLedger) Lookup(collection string, file string) (*LedgerEntry, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if entry, ok := l.entries[ledgerKey(collection, file)]; ok {
+		return &entry, nil
+	}
+	return nil, nil
+}
+
+// Record appends the entry to the ledger file
+func (l *FileLedger) Record(entry LedgerEntry) error {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if _, err := l.file.Write(append(encoded, '\n')); err != nil {
+		return fmt.Errorf("Failed to record %s in ledger %s: %v", entry.File, l.Path, err)
+	}
+	l.entries[ledgerKey(entry.Collection, entry.File)] = entry
+	return nil
+}
+
+// Close ...
+func (l *FileLedger) Close() error {
+	if l.file != nil {
+		return l.file.Close()
+	}
+	return nil
+}
+
+// CollectionLedger stores the ledger in a MongoDB collection
+type CollectionLedger struct {
+	DatabaseName string
+	Collection   string
+	collection   *mongo.Collection
+}
+
+// Open creates a unique index on the collection and file of the entries
+func (l *CollectionLedger) Open(client *mongo.Client) error {
+	if l.DatabaseName == "" || l.Collection == "" {
+		return errors.New("Missing ledger database or collection name")
+	}
+	l.collection = client.Database(l.DatabaseName).Collection(l.Collection)
+	_, err := l.collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "collection", Value: 1}, {Key: "file", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to create index for ledger %s:%s: %v", l.DatabaseName, l.Collection, err)
+	}
+	return nil
+}
+
+// Lookup ...
+func (l *CollectionLedger) Lookup(collection string, file string) (*LedgerEntry, error) {
+	var entry LedgerEntry
+	err := l.collection.FindOne(context.Background(), bson.M{"collection": collection, "file": file}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Record ...
+func (l *CollectionLedger) Record(entry LedgerEntry) error {
+	_, err := l.collection.ReplaceOne(context.Background(),
+		bson.M{"collection": entry.Collection, "file": entry.File},
+		entry, options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("Failed to record %s in ledger %s:%s: %v", entry.File, l.DatabaseName, l.Collection, err)
+	}
+	return nil
+}
+
+// Close ...
+func (l *CollectionLedger) Close() error {
+	return nil
+}
+
+// newLedgerEntry describes the current state of a stream. The hash is computed while the stream is imported
+func newLedgerEntry(stream files.Stream, collection string) *LedgerEntry {
+	entry := &LedgerEntry{
+		File:       stream.Name(),
+		Collection: collection,
+		Size:       stream.Size(),
+	}
+	if local, ok := stream.(*files.LocalFile); ok {
+		if info, err := os.Stat(local.Path); err == nil {
+			entry.ModTime = info.ModTime()
+		}
+	}
+	return entry
+}
+
+// unchanged compares the entry with the previous import of the file.
+// Local files with a different modification time are hashed to detect files that were only touched
+func (entry *LedgerEntry) unchanged(previous *LedgerEntry, stream files.Stream) (bool, error) {
+	if previous == nil || entry.Size < 0 || entry.Size != previous.Size {
+		return false, nil
+	}
+	// Modification times are compared in milliseconds because that is the precision of BSON dates
+	if !entry.ModTime.IsZero() && entry.ModTime.Truncate(time.Millisecond).Equal(previous.ModTime.Truncate(time.Millisecond)) {
+		return true, nil
+	}
+	local, ok := stream.(*files.LocalFile)
+	if !ok || previous.Hash == "" {
+		return false, nil
+	}
+	hash, err := hashFile(local.Path)
+	if err != nil {
+		return false, err
+	}
+	return hash == previous.Hash, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
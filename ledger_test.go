@@ -0,0 +1,102 @@
+package mongoimport
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romnn/mongoimport/files"
+)
+
+func TestFileLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ledger.jsonl")
+
+	ledger := &FileLedger{Path: path}
+	if err := ledger.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	first := LedgerEntry{File: "a.csv", Collection: "people", Size: 10, Hash: "1"}
+	second := LedgerEntry{File: "a.csv", Collection: "people", Size: 12, Hash: "2"}
+	other := LedgerEntry{File: "a.csv", Collection: "other", Size: 5, Hash: "3"}
+	for _, entry := range []LedgerEntry{first, second, other} {
+		if err := ledger.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ledger.Close()
+
+	// Entries are read back when the ledger is opened again
+	ledger = &FileLedger{Path: path}
+	if err := ledger.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+	if entry, err := ledger.Lookup("people", "a.csv"); err != nil || entry == nil || entry.Hash != second.Hash {
+		t.Errorf("Expected the latest entry %v but got %v (%v)", second, entry, err)
+	}
+	if entry, err := ledger.Lookup("other", "a.csv"); err != nil || entry == nil || entry.Hash != other.Hash {
+		t.Errorf("Expected entry %v but got %v (%v)", other, entry, err)
+	}
+	if entry, err := ledger.Lookup("people", "b.csv"); err != nil || entry != nil {
+		t.Errorf("Expected no entry for a file that was never imported but got %v (%v)", entry, err)
+	}
+}
+
+func TestLedgerEntryUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "people.csv")
+	if err := ioutil.WriteFile(path, []byte(basicCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stream := &files.LocalFile{Path: path}
+
+	hash, err := hashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := newLedgerEntry(stream, "people")
+	previous.Hash = hash
+
+	if unchanged, err := newLedgerEntry(stream, "people").unchanged(previous, stream); err != nil || !unchanged {
+		t.Errorf("Expected an unmodified file to be unchanged (%v)", err)
+	}
+
+	// Touched files are compared by their content
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if unchanged, err := newLedgerEntry(stream, "people").unchanged(previous, stream); err != nil || !unchanged {
+		t.Errorf("Expected a touched file with the same content to be unchanged (%v)", err)
+	}
+
+	// Modified content of the same size is detected by the hash
+	if err := ioutil.WriteFile(path, []byte(strings.ToUpper(basicCSV)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if unchanged, err := newLedgerEntry(stream, "people").unchanged(previous, stream); err != nil || unchanged {
+		t.Errorf("Expected a modified file to be changed (%v)", err)
+	}
+
+	if unchanged, _ := newLedgerEntry(stream, "people").unchanged(nil, stream); unchanged {
+		t.Errorf("Expected a file without a ledger entry to be changed")
+	}
+
+	// Streams of unknown size are always imported
+	stdin := files.NewStream("stdin", -1, nil)
+	if unchanged, _ := newLedgerEntry(stdin, "people").unchanged(&LedgerEntry{File: "stdin", Size: -1}, stdin); unchanged {
+		t.Errorf("Expected a stream of unknown size to be changed")
+	}
+}
@@ -0,0 +1,172 @@
+package mongoimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	opt "github.com/romnn/configo"
+	log "github.com/sirupsen/logrus"
+)
+
+// AbortPolicy decides what happens to loaded documents that were not written yet when an import is aborted
+type AbortPolicy string
+
+const (
+	// FlushOnAbort writes the documents of the current batches before stopping
+	FlushOnAbort AbortPolicy = "flush"
+	// DiscardOnAbort drops the documents of the current batches
+	DiscardOnAbort AbortPolicy = "discard"
+)
+
+// ParseAbortPolicy parses the name of an abort policy
+func ParseAbortPolicy(policy string) (AbortPolicy, error) {
+	switch AbortPolicy(strings.ToLower(policy)) {
+	case "", FlushOnAbort:
+		return FlushOnAbort, nil
+	case DiscardOnAbort:
+		return DiscardOnAbort, nil
+	}
+	return "", fmt.Errorf("Unknown abort policy %q (must be one of flush or discard)", policy)
+}
+
+// minErrorRateRecords is the number of records that must be processed before the error rate is checked
+const minErrorRateRecords = 100
+
+// ErrorLimitError is the reason an import was aborted because too many records failed
+type ErrorLimitError struct {
+	// Collection is the collection of the source that exceeded its limits or empty for the limits of the import
+	Collection string
+	Failed     int
+	Processed  int
+	// MaxErrors is the exceeded number of failed records if MaxErrorRate is zero
+	MaxErrors int
+	// MaxErrorRate is the exceeded percentage of failed records
+	MaxErrorRate float64
+}
+
+// Error ...
+func (e *ErrorLimitError) Error() string {
+	scope := "the import"
+	if e.Collection != "" {
+		scope = fmt.Sprintf("source %s", e.Collection)
+	}
+	if e.MaxErrorRate > 0 {
+		return fmt.Sprintf("Aborted because %.1f%% of %d records of %s failed (at most %.1f%% allowed)", errorRate(e.Failed, e.Processed), e.Processed, scope, e.MaxErrorRate)
+	}
+	return fmt.Sprintf("Aborted because %d records of %s failed (at most %d allowed)", e.Failed, scope, e.MaxErrors)
+}
+
+func errorRate(failed, processed int) float64 {
+	return 100 * float64(failed) / float64(processed)
+}
+
+// errorBudget counts processed and failed records against error limits
+type errorBudget struct {
+	collection   string
+	maxErrors    int
+	maxErrorRate float64
+	processed    int
+	failed       int
+	mux          sync.Mutex
+}
+
+// add counts records and returns an *ErrorLimitError once a limit is exceeded
+func (b *errorBudget) add(succeeded, failed int) error {
+	if b == nil || succeeded+failed == 0 {
+		return nil
+	}
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.processed += succeeded + failed
+	b.failed += failed
+	if b.maxErrors >= 0 && b.failed > b.maxErrors {
+		return &ErrorLimitError{Collection: b.collection, Failed: b.failed, Processed: b.processed, MaxErrors: b.maxErrors}
+	}
+	if b.maxErrorRate > 0 && b.processed >= minErrorRateRecords && errorRate(b.failed, b.processed) > b.maxErrorRate {
+		return &ErrorLimitError{Collection: b.collection, Failed: b.failed, Processed: b.processed, MaxErrorRate: b.maxErrorRate}
+	}
+	return nil
+}
+
+// newErrorBudget returns nil if there are no limits to enforce
+func newErrorBudget(collection string, maxErrors int, maxErrorRate float64) *errorBudget {
+	if maxErrors <= 0 && maxErrorRate <= 0 {
+		return nil
+	}
+	if maxErrors <= 0 {
+		// Only the error rate is limited
+		maxErrors = -1
+	}
+	return &errorBudget{collection: collection, maxErrors: maxErrors, maxErrorRate: maxErrorRate}
+}
+
+func (s *Datasource) validateErrorLimits() error {
+	if s.Options.MaxErrors < 0 {
+		return errors.New("The maximum number of errors must not be negative")
+	}
+	if s.Options.MaxErrorRate < 0 || s.Options.MaxErrorRate > 100 {
+		return fmt.Errorf("The maximum error rate must be a percentage but is %v", s.Options.MaxErrorRate)
+	}
+	_, err := ParseAbortPolicy(string(s.Options.OnAbort))
+	return err
+}
+
+func (s *Datasource) abortPolicy() AbortPolicy {
+	policy, _ := ParseAbortPolicy(string(s.Options.OnAbort))
+	return policy
+}
+
+// prepareErrorBudget sets up the error limits of the source, where failing on errors aborts after the first failed record
+func (s *Datasource) prepareErrorBudget() {
+	s.budget = newErrorBudget(s.Collection, s.Options.MaxErrors, s.Options.MaxErrorRate)
+	if opt.Enabled(s.Options.FailOnErrors) {
+		s.budget = &errorBudget{collection: s.Collection, maxErrors: 0, maxErrorRate: s.Options.MaxErrorRate}
+	}
+}
+
+// countRecords counts records towards the error limits of the source and the import and aborts the import once a limit is exceeded
+func (s *Datasource) countRecords(succeeded, failed int) {
+	for _, budget := range []*errorBudget{s.budget, s.owner.budget} {
+		if err := budget.add(succeeded, failed); err != nil {
+			s.owner.abort(err)
+		}
+	}
+}
+
+// abort stops producing and processing files. Only the first reason is recorded
+func (i *Import) abort(reason error) {
+	i.abortOnce.Do(func() {
+		log.Error(reason)
+		i.abortReason = reason
+		i.abortDeadline = time.Now().Add(abortFlushTimeout)
+		close(i.aborted)
+		for _, s := range i.sources {
+			// Watched and followed sources would otherwise wait for new input
+			if stoppable, ok := s.FileProvider.(interface{ Stop() }); ok {
+				stoppable.Stop()
+			}
+		}
+	})
+}
+
+// abortIfCancelled aborts the import once ctx is cancelled
+func (i *Import) abortIfCancelled(ctx context.Context) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	i.abort(fmt.Errorf("Import was cancelled: %w", ctx.Err()))
+	return true
+}
+
+func (i *Import) isAborted() bool {
+	select {
+	case <-i.aborted:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,124 @@
+package mongoimport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	opt "github.com/romnn/configo"
+	"github.com/romnn/mongoimport/files"
+)
+
+type stoppableProvider struct {
+	stopped bool
+}
+
+func (p *stoppableProvider) Prepare() error                               { return nil }
+func (p *stoppableProvider) NextStream() (files.Stream, error)            { return nil, io.EOF }
+func (p *stoppableProvider) FetchDirMetadata(files.MetadataUpdateHandler) {}
+func (p *stoppableProvider) Stop()                                        { p.stopped = true }
+
+func TestParseAbortPolicy(t *testing.T) {
+	for input, expected := range map[string]AbortPolicy{"": FlushOnAbort, "flush": FlushOnAbort, "Discard": DiscardOnAbort} {
+		policy, err := ParseAbortPolicy(input)
+		if err != nil || policy != expected {
+			t.Errorf("Parsed %q as %q (%v) but expected %q", input, policy, err, expected)
+		}
+	}
+	if _, err := ParseAbortPolicy("ignore"); err == nil {
+		t.Errorf("Expected an error for an unknown abort policy")
+	}
+	invalid := &Datasource{Options: Options{MaxErrorRate: 150}}
+	if err := invalid.validateErrorLimits(); err == nil {
+		t.Errorf("Expected an error for an error rate above 100%%")
+	}
+}
+
+func TestErrorBudget(t *testing.T) {
+	if budget := newErrorBudget("people", 0, 0); budget != nil || budget.add(0, 10) != nil {
+		t.Errorf("Expected no limits without a maximum number or rate of errors")
+	}
+
+	budget := newErrorBudget("people", 3, 0)
+	if err := budget.add(10, 3); err != nil {
+		t.Errorf("Expected 3 failed records to be within the limit but got %v", err)
+	}
+	err := budget.add(5, 1)
+	if err == nil {
+		t.Fatalf("Expected the fourth failed record to exceed the limit")
+	}
+	expected := "Aborted because 4 records of source people failed (at most 3 allowed)"
+	if err.Error() != expected {
+		t.Errorf("Error was %q but should be %q", err.Error(), expected)
+	}
+
+	// The error rate is only checked after enough records were processed
+	budget = newErrorBudget("", 0, 10)
+	if err := budget.add(0, 20); err != nil {
+		t.Errorf("Expected the rate not to be checked after 20 records but got %v", err)
+	}
+	if err := budget.add(80, 0); err == nil {
+		t.Errorf("Expected 20%% failed records to exceed the rate of 10%%")
+	} else if limitErr, ok := err.(*ErrorLimitError); !ok || limitErr.Failed != 20 || limitErr.Processed != 100 {
+		t.Errorf("Unexpected error %v", err)
+	} else if !strings.HasPrefix(err.Error(), "Aborted because 20.0% of 100 records of the import failed") {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+}
+
+func TestAbortOnErrors(t *testing.T) {
+	provider := &stoppableProvider{}
+	source := &Datasource{FileProvider: provider, Options: Options{Collection: "people", FailOnErrors: opt.SetFlag(true)}}
+	i := &Import{
+		MaxTotalErrors: 5,
+		sources:        []*Datasource{source},
+		aborted:        make(chan struct{}),
+	}
+	i.budget = newErrorBudget("", i.MaxTotalErrors, i.MaxTotalErrorRate)
+	source.owner = i
+	source.prepareErrorBudget()
+
+	source.countRecords(10, 0)
+	if i.isAborted() {
+		t.Fatalf("Expected the import to continue without failed records")
+	}
+	source.countRecords(0, 1)
+	if !i.isAborted() {
+		t.Fatalf("Expected the first failed record to abort a source that fails on errors")
+	}
+	if limitErr, ok := i.abortReason.(*ErrorLimitError); !ok || limitErr.Collection != "people" {
+		t.Errorf("Expected the source limit as the abort reason but got %v", i.abortReason)
+	}
+	if !provider.stopped {
+		t.Errorf("Expected the provider to be stopped")
+	}
+	// Only the first reason is kept
+	source.countRecords(0, 10)
+	if limitErr := i.abortReason.(*ErrorLimitError); limitErr.Failed != 1 {
+		t.Errorf("Expected the first abort reason to be kept but got %v", i.abortReason)
+	}
+}
+
+func TestAbortIfCancelled(t *testing.T) {
+	provider := &stoppableProvider{}
+	i := &Import{
+		sources: []*Datasource{{FileProvider: provider}},
+		aborted: make(chan struct{}),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if i.abortIfCancelled(ctx) || i.isAborted() {
+		t.Fatalf("Expected the import to continue before it is cancelled")
+	}
+	cancel()
+	if !i.abortIfCancelled(ctx) || !i.isAborted() {
+		t.Fatalf("Expected the import to be aborted once it is cancelled")
+	}
+	if !errors.Is(i.abortReason, context.Canceled) {
+		t.Errorf("Expected the import to be aborted because it was cancelled but got %v", i.abortReason)
+	}
+	if !provider.stopped {
+		t.Errorf("Expected the provider to be stopped")
+	}
+}
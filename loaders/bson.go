@@ -0,0 +1,105 @@
+package loaders
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/romnn/mongoimport/loaders/internal"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxBSONDocumentSize allows some headroom over the 16 MB server limit like bsondump does
+const maxBSONDocumentSize = 16*1024*1024 + 16*1024
+
+// BSONLoader reads length-prefixed BSON documents as written by mongodump
+type BSONLoader struct {
+	reader     io.Reader
+	bsonReader *bufio.Reader
+	documents  int
+	offset     int64
+	position   Position
+	document   bson.D
+	err        error
+}
+
+// OrderedLoader is implemented by loaders that keep the field order of the last loaded document, which maps lose
+type OrderedLoader interface {
+	Document() bson.D
+}
+
+// DefaultBSONLoader ..
+func DefaultBSONLoader() *BSONLoader {
+	return &BSONLoader{}
+}
+
+// Describe ...
+func (bsonl *BSONLoader) Describe() string {
+	return "BSON"
+}
+
+// Create ...
+func (bsonl BSONLoader) Create(reader io.Reader, skipSanitization bool) ImportLoader {
+	return &BSONLoader{
+		reader: reader,
+	}
+}
+
+// Start ...
+func (bsonl *BSONLoader) Start() error {
+	bsonl.bsonReader = bufio.NewReader(bsonl.reader)
+	return nil
+}
+
+// Load reads the next BSON document.
+// Documents that cannot be decoded are reported without aborting the file as long as their length prefix is intact
+func (bsonl *BSONLoader) Load() (map[string]interface{}, error) {
+	if bsonl.err != nil {
+		// The stream is no longer aligned to document boundaries
+		return nil, io.EOF
+	}
+	var header [4]byte
+	if _, err := io.ReadFull(bsonl.bsonReader, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		bsonl.err = err
+		return nil, fmt.Errorf("document %d: %s", bsonl.documents+1, err.Error())
+	}
+	bsonl.documents++
+	bsonl.position = Position{Offset: bsonl.offset}
+	length := int32(binary.LittleEndian.Uint32(header[:]))
+	if length < 5 || length > maxBSONDocumentSize {
+		bsonl.err = fmt.Errorf("invalid document length %d", length)
+		return nil, fmt.Errorf("document %d: %s", bsonl.documents, bsonl.err.Error())
+	}
+	document := make([]byte, length)
+	copy(document, header[:])
+	if _, err := io.ReadFull(bsonl.bsonReader, document[len(header):]); err != nil {
+		bsonl.err = err
+		return nil, fmt.Errorf("document %d: %s", bsonl.documents, err.Error())
+	}
+	bsonl.offset += int64(length)
+	// Embedded documents are decoded in order as well
+	bsonl.document = nil
+	if err := bson.Unmarshal(document, &bsonl.document); err != nil {
+		return nil, fmt.Errorf("document %d: %s", bsonl.documents, err.Error())
+	}
+	return internal.PlainBSONValue(bsonl.document).(map[string]interface{}), nil
+}
+
+// Finish ...
+func (bsonl *BSONLoader) Finish() error {
+	return nil
+}
+
+// Position ...
+func (bsonl *BSONLoader) Position() Position {
+	return bsonl.position
+}
+
+// Document returns the last loaded document in the order of its fields
+func (bsonl *BSONLoader) Document() bson.D {
+	return bsonl.document
+}
@@ -0,0 +1,85 @@
+package loaders
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/romnn/deepequal"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBasicBSONLoading(t *testing.T) {
+	id := primitive.NewObjectID()
+	documents := []bson.D{
+		{{Key: "_id", Value: id}, {Key: "name", Value: "Sally Whittaker"}, {Key: "year", Value: int32(2018)}},
+		{{Key: "name", Value: "Belinda Jameson"}, {Key: "address", Value: bson.D{{Key: "room", Value: "17-D"}}}, {Key: "tags", Value: bson.A{"a", "b"}}},
+	}
+	expected := []map[string]interface{}{
+		{"_id": id, "name": "Sally Whittaker", "year": int32(2018)},
+		{"name": "Belinda Jameson", "address": map[string]interface{}{"room": "17-D"}, "tags": []interface{}{"a", "b"}},
+	}
+	var dump bytes.Buffer
+	for i, document := range documents {
+		raw, err := bson.Marshal(document)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dump.Write(raw)
+		if i == 0 {
+			// A document with a valid length prefix but a corrupt body
+			dump.Write([]byte{6, 0, 0, 0, 42, 0})
+		}
+	}
+
+	loader := &Loader{SpecificLoader: DefaultBSONLoader()}
+	ldr, err := loader.Create(&dump, mockUpdateHandler{})
+	if err != nil {
+		t.Fatal("Failed to create the loader")
+	}
+	ldr.Start()
+	var entries []map[string]interface{}
+	var errs []error
+	for {
+		entry, err := ldr.Load()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if equal, err := deepequal.DeepEqual(ldr.Document(), documents[1]); !equal {
+		t.Errorf("Expected the field order of the last document to be kept:\n%s", err.Error())
+	}
+	if equal, err := deepequal.DeepEqual(entries, expected); !equal {
+		t.Errorf("Entries were %v but should be %v:\n%s", entries, expected, err.Error())
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected a single error for the corrupt document but got %v", errs)
+	}
+	ldr.Finish()
+}
+
+func TestTruncatedBSONLoading(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{{Key: "name", Value: "Sally Whittaker"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader := &Loader{SpecificLoader: DefaultBSONLoader()}
+	ldr, err := loader.Create(bytes.NewReader(raw[:len(raw)-3]), mockUpdateHandler{})
+	if err != nil {
+		t.Fatal("Failed to create the loader")
+	}
+	ldr.Start()
+	if _, err := ldr.Load(); err == nil || err == io.EOF {
+		t.Errorf("Expected an error for the truncated document but got %v", err)
+	}
+	if _, done := ldr.Load(); done != io.EOF {
+		t.Errorf("Loader did not signal EOF after the truncated document")
+	}
+	ldr.Finish()
+}
@@ -0,0 +1,114 @@
+package loaders
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression ...
+type Compression string
+
+const (
+	// NoCompression ...
+	NoCompression Compression = ""
+	// Gzip ...
+	Gzip Compression = "gzip"
+	// Bzip2 ...
+	Bzip2 Compression = "bzip2"
+	// Xz ...
+	Xz Compression = "xz"
+	// Zstd ...
+	Zstd Compression = "zstd"
+)
+
+var compressionMagic = []struct {
+	compression Compression
+	magic       []byte
+}{
+	{Gzip, []byte{0x1f, 0x8b, 0x08}},
+	{Xz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{Zstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// isBzip2 checks the stream header and the block or end of stream magic
+// because "BZh" alone is too likely to appear at the start of a text file
+func isBzip2(header []byte) bool {
+	if len(header) < 10 || !bytes.HasPrefix(header, []byte("BZh")) || header[3] < '1' || header[3] > '9' {
+		return false
+	}
+	block := header[4:10]
+	return bytes.Equal(block, []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}) ||
+		bytes.Equal(block, []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90})
+}
+
+var compressionExtensions = map[string]Compression{
+	".gz":   Gzip,
+	".gzip": Gzip,
+	".bz2":  Bzip2,
+	".xz":   Xz,
+	".zst":  Zstd,
+	".zstd": Zstd,
+}
+
+// CompressionFromExtension returns the compression indicated by the extension of filename
+func CompressionFromExtension(filename string) Compression {
+	return compressionExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// TrimCompressionExtension removes a compression extension such as .gz from filename
+func TrimCompressionExtension(filename string) string {
+	if CompressionFromExtension(filename) != NoCompression {
+		return strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+	return filename
+}
+
+// DetectCompression detects the compression of a stream by its magic bytes.
+// The returned reader must be used in place of reader
+func DetectCompression(reader io.Reader) (Compression, io.Reader) {
+	buffered := bufio.NewReader(reader)
+	for _, c := range compressionMagic {
+		if header, _ := buffered.Peek(len(c.magic)); bytes.Equal(header, c.magic) {
+			return c.compression, buffered
+		}
+	}
+	if header, _ := buffered.Peek(10); isBzip2(header) {
+		return Bzip2, buffered
+	}
+	return NoCompression, buffered
+}
+
+// Decompress transparently decompresses gzip, bzip2, xz and zstd streams.
+// Uncompressed streams are returned as is
+func Decompress(reader io.Reader) (io.ReadCloser, error) {
+	compression, reader := DetectCompression(reader)
+	switch compression {
+	case Gzip:
+		return gzip.NewReader(reader)
+	case Bzip2:
+		return ioutil.NopCloser(bzip2.NewReader(reader)), nil
+	case Xz:
+		xzReader, err := xz.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xzReader), nil
+	case Zstd:
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader.IOReadCloser(), nil
+	default:
+		return ioutil.NopCloser(reader), nil
+	}
+}
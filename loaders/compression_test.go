@@ -0,0 +1,119 @@
+package loaders
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/romnn/deepequal"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	compressionCSV = "name,year\nSally Whittaker,2018\nBelinda Jameson,2017\n"
+	// Created with python: bz2.compress(compressionCSV)
+	compressionCSVBzip2 = []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x39, 0x2d, 0x28, 0x2d, 0x00, 0x00,
+		0x15, 0x5f, 0x80, 0x00, 0x10, 0x40, 0x04, 0x70, 0xc0, 0x10, 0x10, 0x08, 0x80, 0x26, 0x6f, 0x9c,
+		0x20, 0x20, 0x00, 0x48, 0x6a, 0x9e, 0xa6, 0xd2, 0x07, 0x94, 0x36, 0xa3, 0x34, 0x68, 0x50, 0x00,
+		0x00, 0x64, 0xc8, 0xdd, 0xf8, 0xee, 0x1c, 0xd9, 0x42, 0x04, 0x0a, 0x11, 0x48, 0x9a, 0x19, 0x19,
+		0x35, 0xbf, 0x9f, 0x3c, 0xab, 0x77, 0xaf, 0x25, 0x22, 0x18, 0xa6, 0x20, 0xf6, 0x14, 0x38, 0x07,
+		0xc5, 0xdc, 0x91, 0x4e, 0x14, 0x24, 0x0e, 0x4b, 0x4a, 0x0b, 0x40,
+	}
+	compressionCSVEntries = []map[string]interface{}{
+		{"name": "Sally Whittaker", "year": "2018"},
+		{"name": "Belinda Jameson", "year": "2017"},
+	}
+)
+
+type countingUpdateHandler struct {
+	written *int
+}
+
+func (uh countingUpdateHandler) Write(p []byte) (n int, err error) {
+	*uh.written += len(p)
+	return len(p), nil
+}
+
+func compress(t *testing.T, compression Compression, data string) []byte {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+	switch compression {
+	case Gzip:
+		writer = gzip.NewWriter(&buf)
+	case Bzip2:
+		return compressionCSVBzip2
+	case Xz:
+		writer, err = xz.NewWriter(&buf)
+	case Zstd:
+		writer, err = zstd.NewWriter(&buf)
+	default:
+		return []byte(data)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompressedCSVLoading(t *testing.T) {
+	for _, compression := range []Compression{NoCompression, Gzip, Bzip2, Xz, Zstd} {
+		compressed := compress(t, compression, compressionCSV)
+		if detected, _ := DetectCompression(bytes.NewReader(compressed)); detected != compression {
+			t.Errorf("Detected %q compression but expected %q", detected, compression)
+		}
+
+		csvLoader := DefaultCSVLoader()
+		csvLoader.Excel = false
+		loader := &Loader{SpecificLoader: csvLoader}
+		var written int
+		ldr, err := loader.Create(bytes.NewReader(compressed), countingUpdateHandler{written: &written})
+		if err != nil {
+			t.Fatalf("Failed to create the loader for %q compression: %s", compression, err.Error())
+		}
+		if err := ldr.Start(); err != nil {
+			t.Fatalf("Failed to start the loader for %q compression: %s", compression, err.Error())
+		}
+		var entries []map[string]interface{}
+		for {
+			entry, err := ldr.Load()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Failed to load entry with %q compression: %s", compression, err.Error())
+			}
+			entries = append(entries, entry)
+		}
+		if equal, err := deepequal.DeepEqual(entries, compressionCSVEntries); !equal {
+			t.Errorf("Entries with %q compression were %v but should be %v:\n%s", compression, entries, compressionCSVEntries, err.Error())
+		}
+		if written != len(compressed) {
+			t.Errorf("Progress with %q compression tracked %d bytes but the compressed input has %d bytes", compression, written, len(compressed))
+		}
+		ldr.Finish()
+	}
+}
+
+func TestTrimCompressionExtension(t *testing.T) {
+	cases := map[string]string{
+		"people.csv.gz":  "people.csv",
+		"books.xml.zst":  "books.xml",
+		"people.csv":     "people.csv",
+		"archive.tar.XZ": "archive.tar",
+	}
+	for filename, expected := range cases {
+		if trimmed := TrimCompressionExtension(filename); trimmed != expected {
+			t.Errorf("Trimmed %s to %s but expected %s", filename, trimmed, expected)
+		}
+	}
+}
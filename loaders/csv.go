@@ -1,6 +1,7 @@
 package loaders
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"strings"
@@ -22,8 +23,12 @@ type CSVLoader struct {
 	SkipSanitization bool
 
 	reader    io.Reader
+	input     *positionReader
+	buffered  *bufio.Reader
 	csvReader *csv.Reader
 	columns   []string
+	position  Position
+	raw       []byte
 }
 
 // DefaultCSVLoader ..
@@ -49,7 +54,10 @@ func (csvl *CSVLoader) Start() error {
 		dialect.LineTerminator = "\n"
 	}
 
-	csvl.csvReader = csv.NewDialectReader(csvl.reader, dialect)
+	// The csv reader uses the buffered reader as is, which allows to locate records
+	csvl.input = &positionReader{reader: csvl.reader, keep: true}
+	csvl.buffered = bufio.NewReader(csvl.input)
+	csvl.csvReader = csv.NewDialectReader(csvl.buffered, dialect)
 	columns, err := internal.ParseColumns(csvl.csvReader, csvl.SkipHeader, csvl.Fields, !csvl.SkipSanitization)
 	if err != nil {
 		return err
@@ -94,7 +102,10 @@ func (csvl *CSVLoader) Load() (entry map[string]interface{}, err error) {
 	columnCount := len(csvl.columns)
 	log.Debugf("Columns: %v", csvl.columns)
 	cols := make(map[string]interface{}, columnCount)
+	csvl.position = csvl.input.position(csvl.buffered)
+	csvl.input.discardBefore(csvl.position.Offset)
 	record, err := csvl.csvReader.Read()
+	csvl.raw = csvl.input.raw(csvl.position.Offset, csvl.input.position(csvl.buffered).Offset)
 	if err != nil {
 		if err == io.EOF {
 			return nil, err
@@ -115,6 +126,16 @@ func (csvl *CSVLoader) Load() (entry map[string]interface{}, err error) {
 	return cols, nil
 }
 
+// Position ...
+func (csvl *CSVLoader) Position() Position {
+	return csvl.position
+}
+
+// Raw returns the input of the last record as it was read, including quotes
+func (csvl *CSVLoader) Raw() string {
+	return strings.TrimRight(string(csvl.raw), "\r\n")
+}
+
 // ParseDelimiter parses the delimiter for an escape sequence. This allows windows users to pass
 // in \t since they cannot pass "`t" or "$Tab" to the program.
 func ParseDelimiter(delim string, skip bool) string {
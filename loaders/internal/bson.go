@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PlainBSONValue converts nested documents and arrays into the same types
+// the plain JSON decoder produces so hooks do not have to tell them apart
+func PlainBSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.M:
+		m := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			m[key] = PlainBSONValue(child)
+		}
+		return m
+	case bson.D:
+		m := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			m[e.Key] = PlainBSONValue(e.Value)
+		}
+		return m
+	case primitive.A:
+		a := make([]interface{}, len(v))
+		for i, child := range v {
+			a[i] = PlainBSONValue(child)
+		}
+		return a
+	default:
+		return v
+	}
+}
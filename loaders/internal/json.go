@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	opt "github.com/romnn/configo"
+	"github.com/romnn/mongoimport/config"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MapJSONParseResult ...
+type MapJSONParseResult struct {
+	Entry map[string]interface{}
+	Err   error
+	// Offset is the byte offset of the element
+	Offset int64
+}
+
+type jsonPathSegment struct {
+	key      string
+	wildcard bool
+}
+
+// MapJSONReader streams the elements matching a path from a single JSON document
+type MapJSONReader struct {
+	Config      config.JSONReaderConfig
+	ResultsChan chan<- MapJSONParseResult
+	Done        <-chan struct{}
+	path        []jsonPathSegment
+	decoder     *json.Decoder
+}
+
+// parseJSONPath parses paths like `data.items[*]` or `[*]` into segments
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	var segments []jsonPathSegment
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return segments, nil
+	}
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var wildcards int
+		for strings.HasSuffix(key, "[*]") {
+			key = strings.TrimSuffix(key, "[*]")
+			wildcards++
+		}
+		if strings.ContainsAny(key, "[]") {
+			return nil, fmt.Errorf("Invalid JSON path %q: only [*] is supported for arrays", path)
+		}
+		if key != "" {
+			segments = append(segments, jsonPathSegment{key: key})
+		} else if wildcards == 0 {
+			return nil, fmt.Errorf("Invalid JSON path %q: empty key", path)
+		}
+		for i := 0; i < wildcards; i++ {
+			segments = append(segments, jsonPathSegment{wildcard: true})
+		}
+	}
+	return segments, nil
+}
+
+// NewMapJSONReader streams the matching elements to resultsChan until the input ends or done is closed
+func NewMapJSONReader(jsonReader io.Reader, conf config.JSONReaderConfig, resultsChan chan<- MapJSONParseResult, done <-chan struct{}) error {
+	segments, err := parseJSONPath(conf.Path)
+	if err != nil {
+		return err
+	}
+	mjson := MapJSONReader{
+		Config:      conf,
+		ResultsChan: resultsChan,
+		Done:        done,
+		path:        segments,
+		decoder:     json.NewDecoder(jsonReader),
+	}
+	go func() {
+		if err := mjson.walk(mjson.path); err != nil && err != io.EOF && err != errStopped {
+			offset := mjson.decoder.InputOffset()
+			mjson.send(MapJSONParseResult{Err: fmt.Errorf("offset %d: %s", offset, err.Error()), Offset: offset})
+		}
+		close(mjson.ResultsChan)
+	}()
+	return nil
+}
+
+// send returns errStopped instead of blocking once the results are no longer consumed
+func (reader *MapJSONReader) send(result MapJSONParseResult) error {
+	select {
+	case reader.ResultsChan <- result:
+		return nil
+	case <-reader.Done:
+		return errStopped
+	}
+}
+
+// walk descends into the value at the current position of the decoder
+func (reader *MapJSONReader) walk(segments []jsonPathSegment) error {
+	if len(segments) < 1 {
+		offset := reader.decoder.InputOffset()
+		var raw json.RawMessage
+		if err := reader.decoder.Decode(&raw); err != nil {
+			return err
+		}
+		entry, err := DecodeObject(raw, reader.Config)
+		if err != nil {
+			return reader.send(MapJSONParseResult{Err: fmt.Errorf("offset %d: %s", offset, err.Error()), Offset: offset})
+		}
+		return reader.send(MapJSONParseResult{Entry: entry, Offset: offset})
+	}
+
+	token, err := reader.decoder.Token()
+	if err != nil {
+		return err
+	}
+	segment := segments[0]
+	delim, isDelim := token.(json.Delim)
+	switch {
+	case segment.wildcard && isDelim && delim == '[':
+		for reader.decoder.More() {
+			if err := reader.walk(segments[1:]); err != nil {
+				return err
+			}
+		}
+		_, err := reader.decoder.Token()
+		return err
+	case !segment.wildcard && isDelim && delim == '{':
+		for reader.decoder.More() {
+			key, err := reader.decoder.Token()
+			if err != nil {
+				return err
+			}
+			if key == segment.key {
+				err = reader.walk(segments[1:])
+			} else {
+				err = reader.skip()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		_, err := reader.decoder.Token()
+		return err
+	default:
+		// The document does not match the path here
+		return reader.skipRemaining(token)
+	}
+}
+
+// skip skips the next value without keeping it in memory
+func (reader *MapJSONReader) skip() error {
+	token, err := reader.decoder.Token()
+	if err != nil {
+		return err
+	}
+	return reader.skipRemaining(token)
+}
+
+// skipRemaining skips the rest of the value that started with token
+func (reader *MapJSONReader) skipRemaining(token json.Token) error {
+	delim, ok := token.(json.Delim)
+	if !ok || delim == '}' || delim == ']' {
+		return nil
+	}
+	depth := 1
+	for depth > 0 {
+		token, err := reader.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := token.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeObject decodes a single JSON or Extended JSON object depending on the config
+func DecodeObject(data []byte, conf config.JSONReaderConfig) (map[string]interface{}, error) {
+	if opt.Enabled(conf.ExtendedJSON) || opt.Enabled(conf.CanonicalExtendedJSON) {
+		return DecodeExtJSONObject(data, opt.Enabled(conf.CanonicalExtendedJSON))
+	}
+	return DecodeJSONObject(data)
+}
+
+// DecodeJSONObject decodes a single JSON object
+func DecodeJSONObject(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var entry map[string]interface{}
+	if err := decoder.Decode(&entry); err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.New("expected a JSON object but got null")
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON object")
+	}
+	return NormalizeJSONNumbers(entry).(map[string]interface{}), nil
+}
+
+// NormalizeJSONNumbers converts json.Number values into int64 or float64
+// so that integers are not stored as doubles
+func NormalizeJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = NormalizeJSONNumbers(child)
+		}
+		return v
+	case []interface{}:
+		for i, child := range v {
+			v[i] = NormalizeJSONNumbers(child)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// DecodeExtJSONObject decodes a single MongoDB Extended JSON v2 object.
+// Relaxed mode also accepts canonical mode input
+func DecodeExtJSONObject(data []byte, canonical bool) (map[string]interface{}, error) {
+	var entry bson.M
+	if err := bson.UnmarshalExtJSON(data, canonical, &entry); err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.New("expected a JSON object but got null")
+	}
+	return PlainBSONValue(entry).(map[string]interface{}), nil
+}
@@ -0,0 +1,6 @@
+package internal
+
+import "errors"
+
+// errStopped ends parsing once the consumer closed the done channel and no longer reads results
+var errStopped = errors.New("Stopped reading because the results are no longer consumed")
@@ -15,12 +15,15 @@ import (
 type MapXMLParseResult struct {
 	Entry map[string]interface{}
 	Err   error
+	// Offset is the byte offset of the start tag of the element
+	Offset int64
 }
 
 // MapXMLReader ...
 type MapXMLReader struct {
 	Config     config.XMLReaderConfig
 	ResulsChan chan<- MapXMLParseResult
+	Done       <-chan struct{}
 }
 
 var xmlCharsetReader func(charset string, input io.Reader) (io.Reader, error)
@@ -48,8 +51,8 @@ func (b *byteReader) ReadByte() (byte, error) {
 	return c, err
 }
 
-// NewMapXMLReader ...
-func NewMapXMLReader(xmlReader io.Reader, conf config.XMLReaderConfig, resulsChan chan<- MapXMLParseResult, cast ...bool) error {
+// NewMapXMLReader streams the elements at the configured depth to resulsChan until the input ends or done is closed
+func NewMapXMLReader(xmlReader io.Reader, conf config.XMLReaderConfig, resulsChan chan<- MapXMLParseResult, done <-chan struct{}, cast ...bool) error {
 	var r bool
 	if len(cast) == 1 {
 		r = cast[0]
@@ -60,6 +63,7 @@ func NewMapXMLReader(xmlReader io.Reader, conf config.XMLReaderConfig, resulsCha
 
 	var mxml MapXMLReader
 	mxml.ResulsChan = resulsChan
+	mxml.Done = done
 	// Merge configs
 	mxml.Config = conf
 	opt.MergeConfig(&mxml.Config, config.DefaultXMLConfig)
@@ -70,6 +74,16 @@ func NewMapXMLReader(xmlReader io.Reader, conf config.XMLReaderConfig, resulsCha
 	return nil
 }
 
+// send returns errStopped instead of blocking once the results are no longer consumed
+func (reader *MapXMLReader) send(result MapXMLParseResult) error {
+	select {
+	case reader.ResulsChan <- result:
+		return nil
+	case <-reader.Done:
+		return errStopped
+	}
+}
+
 func (reader *MapXMLReader) xmlReaderToMap(rdr io.Reader, r bool) error {
 	p := xml.NewDecoder(rdr)
 	p.CharsetReader = xmlCharsetReader
@@ -118,10 +132,12 @@ func (reader *MapXMLReader) xmlToMapParser(depth int, skey string, a []xml.Attr,
 	}
 
 	for {
+		// The offset before reading a start element is the offset of its start tag
+		offset := p.InputOffset()
 		t, err := p.Token()
 		if err != nil {
 			if err != io.EOF {
-				reader.ResulsChan <- MapXMLParseResult{Err: err}
+				reader.send(MapXMLParseResult{Err: err, Offset: offset})
 				return nil, errors.New("xml.Decoder.Token() - " + err.Error())
 			}
 			return nil, err
@@ -141,7 +157,7 @@ func (reader *MapXMLReader) xmlToMapParser(depth int, skey string, a []xml.Attr,
 			if skey == "" {
 				children, err := reader.xmlToMapParser(depth+1, tt.Name.Local, tt.Attr, p, r)
 				if hasResult {
-					reader.ResulsChan <- MapXMLParseResult{Entry: children, Err: err}
+					reader.send(MapXMLParseResult{Entry: children, Err: err, Offset: offset})
 					return children, err
 				}
 				return nil, err
@@ -151,7 +167,9 @@ func (reader *MapXMLReader) xmlToMapParser(depth int, skey string, a []xml.Attr,
 			// len(nn) == 1, necessarily - it is just an 'n'.
 			nn, err := reader.xmlToMapParser(depth+1, tt.Name.Local, tt.Attr, p, r)
 			if err != nil {
-				reader.ResulsChan <- MapXMLParseResult{Err: err}
+				if err != errStopped {
+					reader.send(MapXMLParseResult{Err: err, Offset: offset})
+				}
 				return nil, err
 			}
 
@@ -206,7 +224,9 @@ func (reader *MapXMLReader) xmlToMapParser(depth int, skey string, a []xml.Attr,
 			}
 
 			if hasResult {
-				reader.ResulsChan <- MapXMLParseResult{Entry: nn}
+				if err := reader.send(MapXMLParseResult{Entry: nn, Offset: offset}); err != nil {
+					return nil, err
+				}
 			}
 
 		case xml.EndElement:
@@ -0,0 +1,117 @@
+package loaders
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/romnn/mongoimport/config"
+	"github.com/romnn/mongoimport/loaders/internal"
+)
+
+// JSONLoader ...
+type JSONLoader struct {
+	Config config.JSONReaderConfig
+
+	reader      io.Reader
+	lineReader  *bufio.Reader
+	line        int
+	offset      int64
+	position    Position
+	raw         []byte
+	err         error
+	resultsChan chan internal.MapJSONParseResult
+	done        chan struct{}
+}
+
+// DefaultJSONLoader ..
+func DefaultJSONLoader() *JSONLoader {
+	return &JSONLoader{}
+}
+
+// Describe ...
+func (jsonl *JSONLoader) Describe() string {
+	return "JSON"
+}
+
+// Create ...
+func (jsonl JSONLoader) Create(reader io.Reader, skipSanitization bool) ImportLoader {
+	return &JSONLoader{
+		reader: reader,
+		Config: jsonl.Config,
+	}
+}
+
+// Start ...
+func (jsonl *JSONLoader) Start() error {
+	if jsonl.Config.Path != "" {
+		// Stream the elements matching the path from a single document
+		jsonl.resultsChan = make(chan internal.MapJSONParseResult)
+		jsonl.done = make(chan struct{})
+		return internal.NewMapJSONReader(jsonl.reader, jsonl.Config, jsonl.resultsChan, jsonl.done)
+	}
+	jsonl.lineReader = bufio.NewReader(jsonl.reader)
+	return nil
+}
+
+// Load reads the next JSON object.
+// Malformed lines are reported with their line number and do not abort the file
+func (jsonl *JSONLoader) Load() (map[string]interface{}, error) {
+	if jsonl.resultsChan != nil {
+		r, ok := <-jsonl.resultsChan
+		if !ok {
+			return nil, io.EOF
+		}
+		jsonl.position = Position{Offset: r.Offset}
+		jsonl.raw = nil
+		return r.Entry, r.Err
+	}
+	if jsonl.err != nil {
+		// A previous read error is not recoverable
+		return nil, io.EOF
+	}
+	for {
+		line, err := jsonl.lineReader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			jsonl.err = err
+			return nil, err
+		}
+		offset := jsonl.offset
+		jsonl.offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) > 0 {
+			jsonl.line++
+			jsonl.position = Position{Line: jsonl.line, Offset: offset}
+			jsonl.raw = line
+			entry, decodeErr := internal.DecodeObject(line, jsonl.Config)
+			if decodeErr != nil {
+				return nil, fmt.Errorf("line %d: %s", jsonl.line, decodeErr.Error())
+			}
+			// A final line without a trailing newline is returned first, EOF follows on the next call
+			return entry, nil
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		jsonl.line++
+	}
+}
+
+// Position ...
+func (jsonl *JSONLoader) Position() Position {
+	return jsonl.position
+}
+
+// Raw returns the last line of newline-delimited JSON
+func (jsonl *JSONLoader) Raw() string {
+	return string(bytes.TrimSpace(jsonl.raw))
+}
+
+// Finish stops streaming the elements of a path if not all of them were loaded
+func (jsonl *JSONLoader) Finish() error {
+	if jsonl.done != nil {
+		close(jsonl.done)
+		jsonl.done = nil
+	}
+	return nil
+}
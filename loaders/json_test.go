@@ -0,0 +1,155 @@
+package loaders
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	opt "github.com/romnn/configo"
+	"github.com/romnn/deepequal"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	basicNDJSON = `{"name": "Sally Whittaker", "year": 2018, "gpa": 3.75}
+
+{"name": "Belinda Jameson", "year": 2017, "tags": ["a", "b"]}
+{"name": "broken",
+{"name": "Jeff Smith", "address": {"house": "Prescott House", "room": "17-D"}}`
+	basicNDJSONEntries = []map[string]interface{}{
+		{"name": "Sally Whittaker", "year": int64(2018), "gpa": 3.75},
+		{"name": "Belinda Jameson", "year": int64(2017), "tags": []interface{}{"a", "b"}},
+		{"name": "Jeff Smith", "address": map[string]interface{}{"house": "Prescott House", "room": "17-D"}},
+	}
+)
+
+func TestBasicJSONLoading(t *testing.T) {
+	loader := &Loader{SpecificLoader: DefaultJSONLoader()}
+	ldr, err := loader.Create(strings.NewReader(basicNDJSON), mockUpdateHandler{})
+	if err != nil {
+		t.Fatal("Failed to create the loader")
+	}
+	ldr.Start()
+	var entries []map[string]interface{}
+	var errs []error
+	for {
+		entry, err := ldr.Load()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != len(basicNDJSONEntries) {
+		t.Fatalf("Loaded %d entries but expected %d: %v", len(entries), len(basicNDJSONEntries), entries)
+	}
+	for i, entry := range entries {
+		if equal, err := deepequal.DeepEqual(entry, basicNDJSONEntries[i]); !equal {
+			t.Errorf("Entry %d was %v but should be %v:\n%s", i, entry, basicNDJSONEntries[i], err.Error())
+		}
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Expected a single error for the malformed line but got %v", errs)
+	}
+	if !strings.HasPrefix(errs[0].Error(), "line 4:") {
+		t.Errorf("Error for the malformed line does not include its line number: %s", errs[0].Error())
+	}
+	ldr.Finish()
+}
+
+func TestJSONPathLoading(t *testing.T) {
+	cases := []struct {
+		path     string
+		document string
+	}{
+		{"[*]", `[{"name": "Sally Whittaker", "year": 2018, "gpa": 3.75}, 42, {"name": "Belinda Jameson", "year": 2017, "tags": ["a", "b"]}]`},
+		{"data.items[*]", `{"meta": {"items": [{"skip": true}]}, "data": {"count": 2, "items": [
+			{"name": "Sally Whittaker", "year": 2018, "gpa": 3.75},
+			"not an object",
+			{"name": "Belinda Jameson", "year": 2017, "tags": ["a", "b"]}
+		]}}`},
+	}
+	for _, c := range cases {
+		jsonLoader := DefaultJSONLoader()
+		jsonLoader.Config.Path = c.path
+		loader := &Loader{SpecificLoader: jsonLoader}
+		ldr, err := loader.Create(strings.NewReader(c.document), mockUpdateHandler{})
+		if err != nil {
+			t.Fatal("Failed to create the loader")
+		}
+		if err := ldr.Start(); err != nil {
+			t.Fatalf("Failed to start the loader: %s", err.Error())
+		}
+		var entries []map[string]interface{}
+		var errs []error
+		for {
+			entry, err := ldr.Load()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		expected := basicNDJSONEntries[:2]
+		if equal, err := deepequal.DeepEqual(entries, expected); !equal {
+			t.Errorf("Entries for path %s were %v but should be %v:\n%s", c.path, entries, expected, err.Error())
+		}
+		if len(errs) != 1 {
+			t.Errorf("Expected a single error for the element that is not an object but got %v", errs)
+		}
+		ldr.Finish()
+	}
+}
+
+func TestExtendedJSONLoading(t *testing.T) {
+	extendedNDJSON := `{"_id": {"$oid": "5f8d0d55b54764421b7156c9"}, "created": {"$date": "2020-10-19T08:00:00Z"}, "price": {"$numberDecimal": "44.95"}, "count": 3}
+{"_id": {"$oid": "5f8d0d55b54764421b7156ca"}, "created": {"$date": {"$numberLong": "1603094400000"}}, "data": {"$binary": {"base64": "aGVsbG8=", "subType": "00"}}, "nested": {"count": {"$numberLong": "3"}}}`
+	jsonLoader := DefaultJSONLoader()
+	jsonLoader.Config.ExtendedJSON = opt.SetFlag(true)
+	loader := &Loader{SpecificLoader: jsonLoader}
+	ldr, err := loader.Create(strings.NewReader(extendedNDJSON), mockUpdateHandler{})
+	if err != nil {
+		t.Fatal("Failed to create the loader")
+	}
+	ldr.Start()
+	entry1, err := ldr.Load()
+	if err != nil {
+		t.Fatalf("Failed to load first entry: %s", err.Error())
+	}
+	entry2, err := ldr.Load()
+	if err != nil {
+		t.Fatalf("Failed to load second entry: %s", err.Error())
+	}
+	if _, ok := entry1["_id"].(primitive.ObjectID); !ok {
+		t.Errorf("Expected an ObjectID but got %T", entry1["_id"])
+	}
+	if created, ok := entry1["created"].(primitive.DateTime); !ok || created != entry2["created"] {
+		t.Errorf("Expected equal dates but got %v (%T) and %v", entry1["created"], entry1["created"], entry2["created"])
+	}
+	if _, ok := entry1["price"].(primitive.Decimal128); !ok {
+		t.Errorf("Expected a Decimal128 but got %T", entry1["price"])
+	}
+	if count, ok := entry1["count"].(int32); !ok || count != 3 {
+		t.Errorf("Expected an int32 but got %v (%T)", entry1["count"], entry1["count"])
+	}
+	if data, ok := entry2["data"].(primitive.Binary); !ok || string(data.Data) != "hello" {
+		t.Errorf("Expected binary data but got %v (%T)", entry2["data"], entry2["data"])
+	}
+	nested, ok := entry2["nested"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected nested document to be a map but got %T", entry2["nested"])
+	}
+	if count, ok := nested["count"].(int64); !ok || count != 3 {
+		t.Errorf("Expected an int64 but got %v (%T)", nested["count"], nested["count"])
+	}
+	if _, done := ldr.Load(); done != io.EOF {
+		t.Errorf("Loader did not signal EOF after the last entry")
+	}
+	ldr.Finish()
+}
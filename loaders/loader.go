@@ -7,6 +7,7 @@ import (
 
 	"github.com/gosuri/uiprogress"
 	"github.com/mitchellh/mapstructure"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // ImportLoader ...
@@ -25,7 +26,7 @@ type Loader struct {
 	file             *os.File
 	read             int64
 	total            int64
-	reader           io.Reader
+	reader           io.ReadCloser
 	Bar              *uiprogress.Bar
 	SkipSanitization bool
 	ready            bool
@@ -59,7 +60,12 @@ func (l *Loader) Create(file io.Reader, updateHandler io.Writer) (*Loader, error
 		SkipSanitization: l.SkipSanitization,
 		ready:            true,
 	}
-	reader := io.TeeReader(file, updateHandler)
+	// Progress is tracked on the compressed bytes so that the totals of the file provider stay accurate
+	reader, err := Decompress(io.TeeReader(file, updateHandler))
+	if err != nil {
+		return nil, err
+	}
+	loader.reader = reader
 	loader.SpecificLoader = l.SpecificLoader.Create(reader, l.SkipSanitization)
 	return loader, nil
 }
@@ -67,10 +73,37 @@ func (l *Loader) Create(file io.Reader, updateHandler io.Writer) (*Loader, error
 // Finish ...
 func (l *Loader) Finish() error {
 	err := l.SpecificLoader.Finish()
+	if l.reader != nil {
+		l.reader.Close()
+	}
 	l.file.Close()
 	return err
 }
 
+// Position returns the position of the last loaded record if the specific loader tracks it
+func (l *Loader) Position() Position {
+	if positioned, ok := l.SpecificLoader.(PositionLoader); ok {
+		return positioned.Position()
+	}
+	return UnknownPosition
+}
+
+// Raw returns the raw input of the last loaded record if the specific loader keeps it
+func (l *Loader) Raw() string {
+	if raw, ok := l.SpecificLoader.(RawLoader); ok {
+		return raw.Raw()
+	}
+	return ""
+}
+
+// Document returns the last loaded document in the order of its fields if the specific loader keeps it
+func (l *Loader) Document() bson.D {
+	if ordered, ok := l.SpecificLoader.(OrderedLoader); ok {
+		return ordered.Document()
+	}
+	return nil
+}
+
 func (l Loader) createStruct(values map[string]interface{}, result interface{}) error {
 	return mapstructure.Decode(values, result)
 }
@@ -0,0 +1,71 @@
+package loaders
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Position locates a record in the uncompressed input
+type Position struct {
+	// Line is the 1-based line where the record starts or 0 if it is not known
+	Line int
+	// Offset is the byte offset where the record starts or -1 if it is not known
+	Offset int64
+}
+
+// UnknownPosition is reported by loaders that do not track positions
+var UnknownPosition = Position{Offset: -1}
+
+// PositionLoader is implemented by loaders that know the position of the last loaded record
+type PositionLoader interface {
+	Position() Position
+}
+
+// RawLoader is implemented by loaders that keep the raw input of the last loaded record
+type RawLoader interface {
+	Raw() string
+}
+
+// positionReader counts the bytes and lines that are read through a buffered reader
+type positionReader struct {
+	reader io.Reader
+	read   int64
+	lines  int
+	// kept holds the bytes read since keptOffset if keep is set, which are the raw input of the current record
+	keep       bool
+	kept       []byte
+	keptOffset int64
+}
+
+func (r *positionReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	r.lines += bytes.Count(p[:n], []byte{'\n'})
+	if r.keep {
+		r.kept = append(r.kept, p[:n]...)
+	}
+	return n, err
+}
+
+// discardBefore drops the kept bytes before the offset
+func (r *positionReader) discardBefore(offset int64) {
+	if drop := offset - r.keptOffset; drop > 0 {
+		r.kept = append(r.kept[:0], r.kept[drop:]...)
+		r.keptOffset = offset
+	}
+}
+
+// raw returns the kept bytes between the offsets
+func (r *positionReader) raw(start, end int64) []byte {
+	return r.kept[start-r.keptOffset : end-r.keptOffset]
+}
+
+// position returns the position of the next byte the buffered reader returns
+func (r *positionReader) position(buffered *bufio.Reader) Position {
+	pending, _ := buffered.Peek(buffered.Buffered())
+	return Position{
+		Line:   r.lines - bytes.Count(pending, []byte{'\n'}) + 1,
+		Offset: r.read - int64(len(pending)),
+	}
+}
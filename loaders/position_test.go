@@ -0,0 +1,120 @@
+package loaders
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func loadPositions(t *testing.T, specific ImportLoader, input io.Reader) []Position {
+	loader := &Loader{SpecificLoader: specific}
+	ldr, err := loader.Create(input, mockUpdateHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ldr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer ldr.Finish()
+	var positions []Position
+	for {
+		if _, err := ldr.Load(); err == io.EOF {
+			break
+		}
+		positions = append(positions, ldr.Position())
+	}
+	return positions
+}
+
+func comparePositions(t *testing.T, format string, positions []Position, expected []Position) {
+	if len(positions) != len(expected) {
+		t.Fatalf("Got %d %s positions %v but expected %v", len(positions), format, positions, expected)
+	}
+	for i := range expected {
+		if positions[i] != expected[i] {
+			t.Errorf("%s record %d is at %+v but should be at %+v", format, i+1, positions[i], expected[i])
+		}
+	}
+}
+
+func TestCSVPositions(t *testing.T) {
+	input := "name,house\nSally Whittaker,McCarren House\n\"Belinda\nJameson\",Cushing House\nJeff Smith,Prescott House\n"
+	csvLoader := DefaultCSVLoader()
+	csvLoader.Excel = false
+	comparePositions(t, "CSV", loadPositions(t, csvLoader, strings.NewReader(input)), []Position{
+		{Line: 2, Offset: 11},
+		{Line: 3, Offset: 42},
+		{Line: 5, Offset: 74},
+	})
+}
+
+func TestJSONPositions(t *testing.T) {
+	comparePositions(t, "JSON", loadPositions(t, DefaultJSONLoader(), strings.NewReader(basicNDJSON)), []Position{
+		{Line: 1, Offset: 0},
+		{Line: 3, Offset: 56},
+		{Line: 4, Offset: 118},
+		{Line: 5, Offset: 137},
+	})
+}
+
+func TestBSONPositions(t *testing.T) {
+	var dump bytes.Buffer
+	var expected []Position
+	for _, name := range []string{"Sally Whittaker", "Belinda Jameson", "Jeff Smith"} {
+		raw, err := bson.Marshal(bson.D{{Key: "name", Value: name}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, Position{Offset: int64(dump.Len())})
+		dump.Write(raw)
+	}
+	comparePositions(t, "BSON", loadPositions(t, DefaultBSONLoader(), &dump), expected)
+}
+
+func TestRawRecords(t *testing.T) {
+	input := "{\"name\": \"Sally Whittaker\"}\n  {\"name\": \"Belinda Jameson\", }  \n"
+	loader := &Loader{SpecificLoader: DefaultJSONLoader()}
+	ldr, err := loader.Create(strings.NewReader(input), mockUpdateHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ldr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer ldr.Finish()
+	if _, err := ldr.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ldr.Load(); err == nil {
+		t.Fatalf("Expected an error for a malformed line")
+	}
+	if raw := ldr.Raw(); raw != "{\"name\": \"Belinda Jameson\", }" {
+		t.Errorf("Raw record of the malformed line is %q", raw)
+	}
+
+	// Raw CSV records keep quoted delimiters and escaped quotes
+	csvLoader := DefaultCSVLoader()
+	csvLoader.Excel = false
+	csvLoader.Fields = "name,house"
+	records := []string{"Sally Whittaker,McCarren House", "\"Jameson, Belinda\",\"Cushing \"\"Old\"\"\nHouse\"", "Jeff Smith,Prescott House"}
+	loader = &Loader{SpecificLoader: csvLoader}
+	ldr, err = loader.Create(strings.NewReader(strings.Join(records, "\n")+"\n"), mockUpdateHandler{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ldr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer ldr.Finish()
+	for _, record := range records {
+		if _, err := ldr.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if raw := ldr.Raw(); raw != record {
+			t.Errorf("Raw CSV record is %q but should be %q", raw, record)
+		}
+	}
+}
@@ -13,6 +13,8 @@ type XMLLoader struct {
 
 	reader      io.Reader
 	resultsChan chan internal.MapXMLParseResult
+	done        chan struct{}
+	position    Position
 }
 
 // DefaultXMLLoader ..
@@ -37,7 +39,8 @@ func (xmll XMLLoader) Create(reader io.Reader, skipSanitization bool) ImportLoad
 // Start ...
 func (xmll *XMLLoader) Start() error {
 	xmll.resultsChan = make(chan internal.MapXMLParseResult)
-	err := internal.NewMapXMLReader(xmll.reader, xmll.Config, xmll.resultsChan)
+	xmll.done = make(chan struct{})
+	err := internal.NewMapXMLReader(xmll.reader, xmll.Config, xmll.resultsChan, xmll.done)
 	if err != nil {
 		return err
 	}
@@ -50,10 +53,20 @@ func (xmll *XMLLoader) Load() (map[string]interface{}, error) {
 	if !ok {
 		return nil, io.EOF
 	}
+	xmll.position = Position{Offset: r.Offset}
 	return r.Entry, r.Err
 }
 
-// Finish ...
+// Position ...
+func (xmll *XMLLoader) Position() Position {
+	return xmll.position
+}
+
+// Finish stops the parser if not all elements were loaded
 func (xmll *XMLLoader) Finish() error {
+	if xmll.done != nil {
+		close(xmll.done)
+		xmll.done = nil
+	}
 	return nil
 }
@@ -1,9 +1,12 @@
 package loaders
 
 import (
+	"bytes"
 	"io"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	opt "github.com/romnn/configo"
 	"github.com/romnn/deepequal"
@@ -88,6 +91,33 @@ func TestBasicXMLLoading(t *testing.T) {
 	ldr.Finish()
 }
 
+// xmlParsers counts the goroutines that parse XML in the background
+func xmlParsers() int {
+	stacks := make([]byte, 1<<20)
+	stacks = stacks[:runtime.Stack(stacks, true)]
+	return bytes.Count(stacks, []byte("internal.NewMapXMLReader.func"))
+}
+
+func TestFinishStopsXMLParser(t *testing.T) {
+	parsers := xmlParsers()
+	loader := &Loader{SpecificLoader: &XMLLoader{}}
+	ldr, err := loader.Create(strings.NewReader(basicXML), mockUpdateHandler{})
+	if err != nil {
+		t.Fatal("Failed to create the loader")
+	}
+	ldr.Start()
+	if _, err := ldr.Load(); err != nil {
+		t.Fatalf("Failed to load first entry: %s", err.Error())
+	}
+	ldr.Finish()
+	// The parser must exit without anyone receiving the second entry that is never loaded
+	for timeout := time.Now().Add(5 * time.Second); xmlParsers() > parsers; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(timeout) {
+			t.Fatal("Parser did not stop after the loader was finished")
+		}
+	}
+}
+
 func TestDeepXMLLoading(t *testing.T) {
 	xmlLoader := &XMLLoader{}
 	xmlLoader.Config.Depth = opt.SetInt(2)
@@ -1,10 +1,14 @@
 package mongoimport
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	opt "github.com/romnn/configo"
@@ -17,6 +21,8 @@ import (
 	tc "github.com/romnn/testcontainers/mongo"
 	"github.com/testcontainers/testcontainers-go"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var (
@@ -129,3 +135,218 @@ func TestBasicCSVImport(t *testing.T) {
 		t.Errorf("%v != %v", namesFound, expected)
 	}
 }
+
+// recordedRejects keeps the rejected records in memory
+type recordedRejects struct {
+	rejects []Reject
+	mux     sync.Mutex
+}
+
+func (r *recordedRejects) Open(client *mongo.Client) error { return nil }
+func (r *recordedRejects) Close() error                    { return nil }
+func (r *recordedRejects) Reject(reject Reject) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.rejects = append(r.rejects, reject)
+	return nil
+}
+
+// prepareNamedCollection creates a collection with a unique index on the name, which rejects documents named "Invalid",
+// and inserts the existing documents
+func prepareNamedCollection(t *testing.T, conn *MongoConnection, name string, existing ...bson.M) *mongo.Collection {
+	client, err := conn.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(conn.DatabaseName)
+	ctx := context.Background()
+	if err := db.RunCommand(ctx, bson.D{{Key: "create", Value: name}, {Key: "validator", Value: bson.M{"name": bson.M{"$ne": "Invalid"}}}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	collection := db.Collection(name)
+	if _, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range existing {
+		if _, err := collection.InsertOne(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return collection
+}
+
+// writeCSVFiles writes each content to a temporary CSV file
+func writeCSVFiles(t *testing.T, dir string, contents ...string) []string {
+	var paths []string
+	for idx, content := range contents {
+		path := filepath.Join(dir, fmt.Sprintf("people%d.csv", idx+1))
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// importNamedCSV imports name and house columns of the files and returns the results of the import and of each file
+func importNamedCSV(t *testing.T, conn *MongoConnection, paths []string, options Options) (ImportResult, map[string]PartialResult) {
+	csvLoader := loaders.DefaultCSVLoader()
+	csvLoader.Excel = false
+	csvLoader.Fields = "name,house"
+	options.Loader = loaders.Loader{SpecificLoader: csvLoader}
+	fileResults := make(map[string]PartialResult)
+	i := Import{
+		Sources:    []*Datasource{{FileProvider: &files.List{Files: paths}, Options: options}},
+		Connection: conn,
+		PartialResultHook: func(result PartialResult) {
+			fileResults[result.File] = result
+		},
+	}
+	result, err := i.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, fileResults
+}
+
+// houses returns the houses of the documents by their name
+func houses(t *testing.T, collection *mongo.Collection) map[string]string {
+	ctx := context.Background()
+	cur, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close(ctx)
+	found := make(map[string]string)
+	for cur.Next(ctx) {
+		var doc struct {
+			Name  string `bson:"name"`
+			House string `bson:"house"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		found[doc.Name] = doc.House
+	}
+	return found
+}
+
+func TestDuplicatePolicyImport(t *testing.T) {
+	mongoC, conn, err := startMongoContainer()
+	if err != nil {
+		t.Fatalf("Failed to start mongoDB container: %v", err)
+	}
+	defer mongoC.Terminate(context.Background())
+	dir, err := ioutil.TempDir("", "duplicates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := writeCSVFiles(t, dir, "Sally Whittaker,McCarren House\nJeff Smith,Prescott House\nSandy Allen,Oliver House\n")
+
+	cases := []struct {
+		policy                DuplicatePolicy
+		ordered               bool
+		succeeded, failed     int
+		expected              map[string]string
+		duplicateRecordErrors int
+	}{
+		{FailOnDuplicate, false, 2, 1, map[string]string{"Sally Whittaker": "McCarren House", "Jeff Smith": "Old House", "Sandy Allen": "Oliver House"}, 1},
+		// The ordered write stops at the duplicate
+		{FailOnDuplicate, true, 1, 2, map[string]string{"Sally Whittaker": "McCarren House", "Jeff Smith": "Old House"}, 1},
+		{SkipDuplicates, false, 2, 0, map[string]string{"Sally Whittaker": "McCarren House", "Jeff Smith": "Old House", "Sandy Allen": "Oliver House"}, 0},
+		// Skipped duplicates do not stop the ordered write
+		{SkipDuplicates, true, 2, 0, map[string]string{"Sally Whittaker": "McCarren House", "Jeff Smith": "Old House", "Sandy Allen": "Oliver House"}, 0},
+		{OverwriteDuplicates, false, 3, 0, map[string]string{"Sally Whittaker": "McCarren House", "Jeff Smith": "Prescott House", "Sandy Allen": "Oliver House"}, 0},
+		{OverwriteDuplicates, true, 3, 0, map[string]string{"Sally Whittaker": "McCarren House", "Jeff Smith": "Prescott House", "Sandy Allen": "Oliver House"}, 0},
+	}
+	for _, c := range cases {
+		name := fmt.Sprintf("%s_ordered_%v", c.policy, c.ordered)
+		collection := prepareNamedCollection(t, conn, name, bson.M{"name": "Jeff Smith", "house": "Old House"})
+		result, fileResults := importNamedCSV(t, conn, paths, Options{
+			Collection:   name,
+			OnDuplicate:  c.policy,
+			WriteOptions: WriteOptions{Ordered: opt.SetFlag(c.ordered)},
+		})
+		if result.Succeeded != c.succeeded || result.Failed != c.failed || result.Duplicates != 1 {
+			t.Errorf("%s: %d succeeded, %d failed and %d duplicates but expected %d succeeded, %d failed and 1 duplicate", name, result.Succeeded, result.Failed, result.Duplicates, c.succeeded, c.failed)
+		}
+		if found := houses(t, collection); !reflect.DeepEqual(found, c.expected) {
+			t.Errorf("%s: documents are %v but should be %v", name, found, c.expected)
+		}
+		recordErrors := fileResults[paths[0]].RecordErrors
+		if len(recordErrors) != c.duplicateRecordErrors {
+			t.Errorf("%s: expected %d record errors but got %v", name, c.duplicateRecordErrors, recordErrors)
+		} else if len(recordErrors) > 0 && (recordErrors[0].Record != 2 || recordErrors[0].Line != 2) {
+			t.Errorf("%s: expected the duplicate in record 2 but got %v", name, recordErrors[0])
+		}
+	}
+
+	// Documents with the same upsert fields are replaced
+	collection := prepareNamedCollection(t, conn, "upserted", bson.M{"name": "Jeff Smith", "house": "Old House"})
+	result, _ := importNamedCSV(t, conn, paths, Options{Collection: "upserted", WriteMode: UpsertMode, UpsertFields: []string{"name"}})
+	if result.Succeeded != 3 || result.Failed != 0 || result.Matched != 1 || result.Modified != 1 || result.Upserted != 2 {
+		t.Errorf("Unexpected upsert result: %s", result.Summary())
+	}
+	expected := map[string]string{"Sally Whittaker": "McCarren House", "Jeff Smith": "Prescott House", "Sandy Allen": "Oliver House"}
+	if found := houses(t, collection); !reflect.DeepEqual(found, expected) {
+		t.Errorf("Upserted documents are %v but should be %v", found, expected)
+	}
+}
+
+func TestWriteErrorRecords(t *testing.T) {
+	mongoC, conn, err := startMongoContainer()
+	if err != nil {
+		t.Fatalf("Failed to start mongoDB container: %v", err)
+	}
+	defer mongoC.Terminate(context.Background())
+	dir, err := ioutil.TempDir("", "records")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := writeCSVFiles(t, dir,
+		// The duplicate is skipped before the invalid document fails
+		"Sally Whittaker,McCarren House\nJeff Smith,Prescott House\nBelinda Jameson,Cushing House\nInvalid,Nowhere\nMark Lee,Oliver House\n",
+		"Lisa Adams,Oliver House\nSandy Allen,Oliver House\nInvalid,Nowhere\n",
+	)
+
+	for _, ordered := range []bool{false, true} {
+		// Rejected records by file
+		expected := map[string][]int{paths[0]: {4}, paths[1]: {3}}
+		if ordered {
+			// The ordered write of the first file stops at the invalid document
+			expected[paths[0]] = []int{4, 5}
+		}
+		name := fmt.Sprintf("records_ordered_%v", ordered)
+		prepareNamedCollection(t, conn, name, bson.M{"name": "Jeff Smith"}, bson.M{"name": "Sandy Allen"})
+		rejects := &recordedRejects{}
+		_, fileResults := importNamedCSV(t, conn, paths, Options{
+			Collection:   name,
+			OnDuplicate:  SkipDuplicates,
+			Rejects:      rejects,
+			WriteOptions: WriteOptions{Ordered: opt.SetFlag(ordered)},
+		})
+
+		rejected := make(map[string][]int)
+		for _, reject := range rejects.rejects {
+			if reject.Stage != WriteStage {
+				t.Errorf("%s: expected write rejects but got %+v", name, reject)
+			}
+			rejected[reject.File] = append(rejected[reject.File], reject.Record)
+		}
+		for _, records := range rejected {
+			sort.Ints(records)
+		}
+		if !reflect.DeepEqual(rejected, expected) {
+			t.Errorf("%s: rejected records are %v but should be %v", name, rejected, expected)
+		}
+		// Only the invalid documents are record errors
+		for file, record := range map[string]int{paths[0]: 4, paths[1]: 3} {
+			recordErrors := fileResults[file].RecordErrors
+			if len(recordErrors) != 1 || recordErrors[0].File != file || recordErrors[0].Record != record || recordErrors[0].Line != record {
+				t.Errorf("%s: expected the invalid document in record %d of %s but got %v", name, record, file, recordErrors)
+			}
+		}
+	}
+}
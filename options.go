@@ -1,23 +1,42 @@
 package mongoimport
 
 import (
+	"time"
+
 	opt "github.com/romnn/configo"
 	"github.com/romnn/mongoimport/loaders"
 )
 
 // Options ...
 type Options struct {
-	DatabaseName       string
-	Collection         string
-	Loader             loaders.Loader
-	PostLoad           PostLoadHook
-	PreDump            PreDumpHook
-	UpdateFilter       UpdateFilterHook
+	DatabaseName string
+	Collection   string
+	Loader       loaders.Loader
+	PostLoad     PostLoadHook
+	PreDump      PreDumpHook
+	// UpdateFilter was never called.
+	//
+	// Deprecated: Use WriteFilter to select the existing documents of write modes other than insert
+	UpdateFilter UpdateFilterHook
+	// WriteFilter selects the existing document for write modes other than insert instead of the upsert fields
+	WriteFilter        WriteFilterHook
+	WriteMode          WriteMode
+	UpsertFields       []string
+	OnDuplicate        DuplicatePolicy
+	WriteOptions       WriteOptions
+	Rejects            RejectSink
+	MaxErrors          int
+	MaxErrorRate       float64
+	OnAbort            AbortPolicy
 	EmptyCollection    *opt.Flag
 	Sanitize           *opt.Flag
 	FailOnErrors       *opt.Flag
 	CollectErrors      *opt.Flag
 	IndividualProgress *opt.Flag
 	ShowCurrentFile    *opt.Flag
+	RestoreMetadata    *opt.Flag
+	Resume             *opt.Flag
 	InsertionBatchSize int
+	// FlushInterval inserts partial batches after no new entries were loaded for the interval
+	FlushInterval time.Duration
 }
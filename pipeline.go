@@ -0,0 +1,135 @@
+package mongoimport
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	opt "github.com/romnn/configo"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// insertBatch is a batch of documents of a single file that is written by an inserter
+type insertBatch struct {
+	file *fileWrites
+	// seq is the position of the batch among the batches of the file
+	seq     int
+	models  []mongo.WriteModel
+	sources []recordSource
+	// records is the number of records of the file whose documents were all batched with this batch
+	records int
+}
+
+// fileWrites collects the results of the batches of a file, which are written concurrently.
+// Batches only hold documents of one file so that write results and checkpoints can be attributed to the file
+type fileWrites struct {
+	job     ImportJob
+	inserts chan<- insertBatch
+	pending sync.WaitGroup
+	batches int
+	mux     sync.Mutex
+	result  PartialResult
+	// written holds the records of written batches that wait for earlier batches before they are checkpointed
+	written      map[int]int
+	checkpointed int
+}
+
+func newFileWrites(job ImportJob, inserts chan<- insertBatch) *fileWrites {
+	return &fileWrites{job: job, inserts: inserts, written: make(map[int]int)}
+}
+
+// enqueue blocks until an inserter accepts the batch if all inserters are busy
+func (f *fileWrites) enqueue(models []mongo.WriteModel, sources []recordSource, records int) {
+	if len(models) == 0 {
+		return
+	}
+	if f.job.Source.sequentialBatches() {
+		f.pending.Wait()
+	}
+	f.pending.Add(1)
+	f.inserts <- insertBatch{file: f, seq: f.batches, models: models, sources: sources, records: records}
+	f.batches++
+}
+
+// sequentialBatches is true if the batches of a file are written one after another.
+// This is required for ordered writes and for writes that replace earlier records with the same key,
+// which would otherwise be overwritten by an earlier record whose batch finished last
+func (s *Datasource) sequentialBatches() bool {
+	return opt.Enabled(s.Options.WriteOptions.Ordered) || s.writeMode() != InsertMode || s.duplicatePolicy() == OverwriteDuplicates
+}
+
+// done adds the result of a batch and checkpoints the records of all batches that were written without gaps.
+// Batches that were not written completely are never checkpointed, so resuming writes them again
+func (f *fileWrites) done(batch insertBatch, result PartialResult, written bool) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.result.add(result)
+	if !written {
+		return
+	}
+	f.written[batch.seq] = batch.records
+	records := -1
+	for {
+		written, ok := f.written[f.checkpointed]
+		if !ok {
+			break
+		}
+		delete(f.written, f.checkpointed)
+		f.checkpointed++
+		records = written
+	}
+	if records >= 0 {
+		f.job.Source.saveCheckpoint(f.job, records, false)
+	}
+}
+
+// finish waits for all batches of the file to be written and checkpoints the records unless a batch was discarded or failed
+func (f *fileWrites) finish(records int, completed bool) PartialResult {
+	f.pending.Wait()
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.checkpointed == f.batches {
+		f.job.Source.saveCheckpoint(f.job, records, completed)
+	}
+	return f.result
+}
+
+// startInserters starts the inserters that write the batches of all files until inserts is closed
+func (i *Import) startInserters(wg *sync.WaitGroup, inserts <-chan insertBatch) {
+	for w := 1; w <= i.Inserters; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for batch := range inserts {
+				batch.file.job.Source.insert(batch)
+			}
+			log.Debugf("inserter %d exited", id)
+		}(w)
+	}
+}
+
+// abortFlushTimeout limits the time spent writing the queued batches of all files once the import was aborted
+const abortFlushTimeout = 30 * time.Second
+
+// insert writes a batch and counts its records towards the error limits.
+// Batches of an aborted import are written even if the import was cancelled unless they should be discarded
+func (s *Datasource) insert(batch insertBatch) {
+	defer batch.file.pending.Done()
+	ctx := s.owner.ctx
+	// The import may be cancelled before the watcher of the context recorded the abort
+	if s.owner.abortIfCancelled(ctx) || s.owner.isAborted() {
+		if s.abortPolicy() == DiscardOnAbort {
+			// The discarded documents are imported again when resuming
+			log.Warnf("Discarding %d documents of %s", len(batch.models), batch.file.job.File)
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(context.Background(), s.owner.abortDeadline)
+		defer cancel()
+	}
+	var result PartialResult
+	written := s.writeBatch(ctx, batch.file.job, batch.models, batch.sources, s.duplicatePolicy(), &result)
+	batch.file.done(batch, result, written)
+	s.countRecords(result.Succeeded, result.Failed)
+}
@@ -0,0 +1,99 @@
+package mongoimport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/romnn/mongoimport/files"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type recordedCheckpoints struct {
+	saved []Checkpoint
+}
+
+func (c *recordedCheckpoints) Open(client *mongo.Client) error { return nil }
+func (c *recordedCheckpoints) Load(collection string, file string) (*Checkpoint, error) {
+	return nil, nil
+}
+func (c *recordedCheckpoints) Save(checkpoint Checkpoint) error {
+	c.saved = append(c.saved, checkpoint)
+	return nil
+}
+func (c *recordedCheckpoints) Close() error { return nil }
+
+func TestFileWritesCheckpoints(t *testing.T) {
+	store := &recordedCheckpoints{}
+	job := ImportJob{
+		Source:      &Datasource{Options: Options{Collection: "people"}},
+		File:        "people.csv",
+		Stream:      files.NewStream("people.csv", 100, nil),
+		Checkpoints: store,
+	}
+	inserts := make(chan insertBatch, 4)
+	writes := newFileWrites(job, inserts)
+	model := []mongo.WriteModel{mongo.NewInsertOneModel().SetDocument(map[string]interface{}{})}
+	for _, records := range []int{10, 20, 25, 28} {
+		writes.enqueue(model, []recordSource{{record: records - 1}}, records)
+	}
+	writes.enqueue(nil, nil, 30)
+	if len(inserts) != 4 {
+		t.Fatalf("Expected empty batches not to be queued but %d batches were queued", len(inserts))
+	}
+	first, second := <-inserts, <-inserts
+
+	// Batches written before earlier batches are not checkpointed
+	writes.done(second, PartialResult{Succeeded: 1}, true)
+	writes.pending.Done()
+	if len(store.saved) != 0 {
+		t.Fatalf("Expected no checkpoint before the first batch was written but got %+v", store.saved)
+	}
+	writes.done(first, PartialResult{Succeeded: 1}, true)
+	writes.pending.Done()
+	if len(store.saved) != 1 || store.saved[0].Records != 20 || store.saved[0].Completed {
+		t.Fatalf("Expected a checkpoint after both batches but got %+v", store.saved)
+	}
+
+	// Records of a batch that failed are written again when resuming, so later batches are not checkpointed either
+	failed, fourth := <-inserts, <-inserts
+	writes.done(failed, PartialResult{Failed: 1}, false)
+	writes.pending.Done()
+	writes.done(fourth, PartialResult{Succeeded: 1}, true)
+	writes.pending.Done()
+	result := writes.finish(30, true)
+	if len(store.saved) != 1 {
+		t.Errorf("Expected no checkpoint after a failed batch but got %+v", store.saved)
+	}
+	if result.Succeeded != 3 || result.Failed != 1 {
+		t.Errorf("Expected the results of all batches but got %d succeeded and %d failed", result.Succeeded, result.Failed)
+	}
+}
+
+func TestFileWritesSequentialUpserts(t *testing.T) {
+	job := ImportJob{
+		Source: &Datasource{Options: Options{Collection: "people", WriteMode: UpsertMode, UpsertFields: []string{"name"}}},
+		File:   "people.csv",
+	}
+	inserts := make(chan insertBatch, 2)
+	writes := newFileWrites(job, inserts)
+	// Both batches upsert the same key, so the second batch must be written last
+	model := []mongo.WriteModel{mongo.NewReplaceOneModel().SetFilter(map[string]interface{}{"name": "Sally Whittaker"})}
+	writes.enqueue(model, []recordSource{{record: 0}}, 1)
+	enqueued := make(chan struct{})
+	go func() {
+		defer close(enqueued)
+		writes.enqueue(model, []recordSource{{record: 1}}, 2)
+	}()
+	first := <-inserts
+	select {
+	case <-enqueued:
+		t.Fatal("Expected the second batch to wait for the first batch to be written")
+	case <-time.After(50 * time.Millisecond):
+	}
+	writes.done(first, PartialResult{Succeeded: 1}, true)
+	writes.pending.Done()
+	<-enqueued
+	if second := <-inserts; second.seq != 1 {
+		t.Errorf("Expected the second batch after the first but got batch %d", second.seq)
+	}
+}
@@ -0,0 +1,144 @@
+package mongoimport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RejectStage is the stage of the import pipeline that rejected a record
+type RejectStage string
+
+const (
+	// LoadStage rejects records that could not be parsed
+	LoadStage RejectStage = "load"
+	// PostLoadStage rejects records that failed the post load hook
+	PostLoadStage RejectStage = "post-load"
+	// PreDumpStage rejects records that failed the pre dump hook
+	PreDumpStage RejectStage = "pre-dump"
+	// WriteStage rejects documents that could not be written
+	WriteStage RejectStage = "write"
+)
+
+// Reject is a record that could not be imported
+type Reject struct {
+	File       string `bson:"file" json:"file"`
+	Collection string `bson:"collection" json:"collection"`
+	// Record is the 1-based index of the record in the file
+	Record int         `bson:"record" json:"record"`
+	Line   int         `bson:"line,omitempty" json:"line,omitempty"`
+	Offset int64       `bson:"offset" json:"offset"`
+	Stage  RejectStage `bson:"stage" json:"stage"`
+	Error  string      `bson:"error" json:"error"`
+	// Raw is the raw input of the record if the loader provides it, otherwise the loaded entry or document
+	Raw        interface{} `bson:"raw,omitempty" json:"raw,omitempty"`
+	RejectedAt time.Time   `bson:"rejectedAt" json:"rejectedAt"`
+}
+
+// RejectSink stores rejected records so that they can be fixed and imported again
+type RejectSink interface {
+	// Open is called with the client of the import before any file is imported
+	Open(client *mongo.Client) error
+	// Reject stores a rejected record and must be safe for concurrent use
+	Reject(reject Reject) error
+	Close() error
+}
+
+// FileRejects appends rejected records as newline-delimited JSON to a local file
+type FileRejects struct {
+	Path string
+	file *os.File
+	mux  sync.Mutex
+}
+
+// Open ...
+func (r *FileRejects) Open(client *mongo.Client) error {
+	if r.Path == "" {
+		return errors.New("Missing reject file path")
+	}
+	file, err := os.OpenFile(r.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	return nil
+}
+
+// Reject ...
+func (r *FileRejects) Reject(reject Reject) error {
+	encoded, err := json.Marshal(reject)
+	if err != nil {
+		return err
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, err := r.file.Write(append(encoded, '\n')); err != nil {
+		return fmt.Errorf("Failed to write reject of %s to %s: %v", reject.File, r.Path, err)
+	}
+	return nil
+}
+
+// Close ...
+func (r *FileRejects) Close() error {
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
+
+// CollectionRejects inserts rejected records into a MongoDB collection
+type CollectionRejects struct {
+	DatabaseName string
+	Collection   string
+	collection   *mongo.Collection
+}
+
+// Open ...
+func (r *CollectionRejects) Open(client *mongo.Client) error {
+	if r.DatabaseName == "" || r.Collection == "" {
+		return errors.New("Missing reject database or collection name")
+	}
+	r.collection = client.Database(r.DatabaseName).Collection(r.Collection)
+	return nil
+}
+
+// Reject ...
+func (r *CollectionRejects) Reject(reject Reject) error {
+	if _, err := r.collection.InsertOne(context.Background(), reject); err != nil {
+		return fmt.Errorf("Failed to insert reject of %s into %s:%s: %v", reject.File, r.DatabaseName, r.Collection, err)
+	}
+	return nil
+}
+
+// Close ...
+func (r *CollectionRejects) Close() error {
+	return nil
+}
+
+// reject stores a rejected record in the reject sink of the source if there is one
+func (s *Datasource) reject(job ImportJob, stage RejectStage, source recordSource, raw interface{}, err error) {
+	if s.Options.Rejects == nil {
+		return
+	}
+	reject := Reject{
+		File:       job.File,
+		Collection: s.Collection,
+		Record:     source.record + 1,
+		Line:       source.position.Line,
+		Offset:     source.position.Offset,
+		Stage:      stage,
+		Error:      err.Error(),
+		Raw:        raw,
+		RejectedAt: time.Now(),
+	}
+	if err := s.Options.Rejects.Reject(reject); err != nil {
+		log.Warn(err)
+	}
+}
@@ -0,0 +1,70 @@
+package mongoimport
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	opt "github.com/romnn/configo"
+	"github.com/romnn/mongoimport/loaders"
+)
+
+func TestFileRejects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rejects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rejects.jsonl")
+
+	// The reject sink of the import is used by sources without their own
+	sink := &FileRejects{Path: path}
+	source := &Datasource{Options: Options{Collection: "people"}}
+	opt.MergeConfig(&source.Options, Options{Rejects: sink})
+	if source.Options.Rejects != sink {
+		t.Fatalf("Expected the source to inherit the reject sink of the import")
+	}
+	if err := sink.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	job := ImportJob{File: "people.csv"}
+	source.reject(job, LoadStage, recordSource{record: 1, position: loaders.Position{Line: 3, Offset: 42}}, "Belinda,Jameson,Cushing House", errors.New("wrong number of fields"))
+	source.reject(job, WriteStage, recordSource{record: 2, position: loaders.UnknownPosition}, map[string]interface{}{"name": "Jeff Smith"}, errors.New("Document failed validation"))
+	sink.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var rejects []Reject
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var reject Reject
+		if err := json.Unmarshal(scanner.Bytes(), &reject); err != nil {
+			t.Fatal(err)
+		}
+		rejects = append(rejects, reject)
+	}
+	if len(rejects) != 2 {
+		t.Fatalf("Expected 2 rejects but got %d", len(rejects))
+	}
+	load := rejects[0]
+	if load.File != "people.csv" || load.Collection != "people" || load.Record != 2 || load.Line != 3 || load.Offset != 42 || load.Stage != LoadStage {
+		t.Errorf("Unexpected load reject %+v", load)
+	}
+	if load.Raw != "Belinda,Jameson,Cushing House" || load.Error != "wrong number of fields" {
+		t.Errorf("Expected the raw record and error of the load reject but got %+v", load)
+	}
+	write := rejects[1]
+	if write.Stage != WriteStage || write.Record != 3 || write.Line != 0 || write.Offset != -1 {
+		t.Errorf("Unexpected write reject %+v", write)
+	}
+	if doc, ok := write.Raw.(map[string]interface{}); !ok || doc["name"] != "Jeff Smith" {
+		t.Errorf("Expected the rejected document but got %v", write.Raw)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"path/filepath"
 	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // LoggableResult ...
@@ -18,13 +21,34 @@ type ImportResult struct {
 	Description    string
 	Succeeded      int
 	Failed         int
+	Skipped        int
+	Inserted       int
+	Matched        int
+	Modified       int
+	Upserted       int
+	Duplicates     int
 	Elapsed        time.Duration
 	PartialResults []SourceResult
+	// Aborted is the reason the import was aborted before all files were imported
+	Aborted error
 }
 
 // Summary ...
 func (ir ImportResult) Summary() string {
-	return fmt.Sprintf("[TOTAL]: %d rows from %d sources (%d files) were imported successfully and %d failed in %s", ir.Succeeded, ir.TotalSources, ir.TotalFiles, ir.Failed, ir.Elapsed)
+	summary := fmt.Sprintf("[TOTAL]: %d rows from %d sources (%d files) were imported successfully and %d failed in %s", ir.Succeeded, ir.TotalSources, ir.TotalFiles, ir.Failed, ir.Elapsed)
+	if ir.Matched > 0 || ir.Upserted > 0 {
+		summary += fmt.Sprintf(" (%d inserted, %d matched, %d modified, %d upserted)", ir.Inserted, ir.Matched, ir.Modified, ir.Upserted)
+	}
+	if ir.Duplicates > 0 {
+		summary += fmt.Sprintf(", %d duplicates", ir.Duplicates)
+	}
+	if ir.Skipped > 0 {
+		summary += fmt.Sprintf(", %d unchanged files were skipped", ir.Skipped)
+	}
+	if ir.Aborted != nil {
+		summary += fmt.Sprintf(". %v", ir.Aborted)
+	}
+	return summary
 }
 
 // SourceResult ...
@@ -34,6 +58,12 @@ type SourceResult struct {
 	Description    string
 	Succeeded      int
 	Failed         int
+	Skipped        int
+	Inserted       int
+	Matched        int
+	Modified       int
+	Upserted       int
+	Duplicates     int
 	Elapsed        time.Duration
 	PartialResults []PartialResult
 }
@@ -45,13 +75,20 @@ func (ir SourceResult) Summary() string {
 
 // PartialResult ...
 type PartialResult struct {
-	File       string
-	Collection string
-	Source     *Datasource
-	Succeeded  int
-	Failed     int
-	Elapsed    time.Duration
-	Errors     []error
+	File               string
+	Collection         string
+	Source             *Datasource
+	Succeeded          int
+	Failed             int
+	Inserted           int
+	Matched            int
+	Modified           int
+	Upserted           int
+	Duplicates         int
+	DuplicateDocuments []interface{}
+	Elapsed            time.Duration
+	Errors             []error
+	RecordErrors       []*RecordError
 }
 
 // Summary ...
@@ -59,3 +96,75 @@ func (ir PartialResult) Summary() string {
 	filename := filepath.Base(ir.File)
 	return fmt.Sprintf("[%s -> %s]: %d rows were imported successfully and %d failed in %s", filename, ir.Collection, ir.Succeeded, ir.Failed, ir.Elapsed)
 }
+
+// addWriteResult counts the documents written by a bulk write
+func (ir *PartialResult) addWriteResult(result *mongo.BulkWriteResult) {
+	if result == nil {
+		return
+	}
+	ir.Inserted += int(result.InsertedCount)
+	ir.Matched += int(result.MatchedCount)
+	ir.Modified += int(result.ModifiedCount)
+	ir.Upserted += int(result.UpsertedCount)
+}
+
+// add merges the counts and errors of another result of the same file
+func (ir *PartialResult) add(other PartialResult) {
+	ir.Succeeded += other.Succeeded
+	ir.Failed += other.Failed
+	ir.Inserted += other.Inserted
+	ir.Matched += other.Matched
+	ir.Modified += other.Modified
+	ir.Upserted += other.Upserted
+	ir.Duplicates += other.Duplicates
+	ir.addDuplicateDocuments(other.DuplicateDocuments...)
+	ir.Errors = append(ir.Errors, other.Errors...)
+	ir.RecordErrors = append(ir.RecordErrors, other.RecordErrors...)
+}
+
+// RecordError is the error of a single record together with its position in the source file
+type RecordError struct {
+	File string
+	// Record is the 1-based index of the record in the file
+	Record int
+	// Line is the 1-based line of the record or 0 if the loader does not track lines
+	Line int
+	// Offset is the byte offset of the record in the uncompressed file or -1 if it is not known
+	Offset int64
+	Err    error
+}
+
+// Error ...
+func (e *RecordError) Error() string {
+	position := fmt.Sprintf("record %d", e.Record)
+	if e.Line > 0 {
+		position += fmt.Sprintf(", line %d", e.Line)
+	}
+	if e.Offset >= 0 {
+		position += fmt.Sprintf(", offset %d", e.Offset)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.File, position, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// maxDuplicateDocuments limits the collected duplicates of a file, which are all counted and sent to the rejects
+const maxDuplicateDocuments = 1000
+
+// addDuplicateDocuments collects duplicates until the limit is reached
+func (ir *PartialResult) addDuplicateDocuments(docs ...interface{}) {
+	if free := maxDuplicateDocuments - len(ir.DuplicateDocuments); len(docs) > free {
+		docs = docs[:free]
+	}
+	ir.DuplicateDocuments = append(ir.DuplicateDocuments, docs...)
+}
+
+// addRecordError reports an error of a single record
+func (ir *PartialResult) addRecordError(err *RecordError) {
+	log.Warn(err)
+	ir.Errors = append(ir.Errors, err)
+	ir.RecordErrors = append(ir.RecordErrors, err)
+}
@@ -2,8 +2,8 @@ package mongoimport
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/gosuri/uiprogress"
 	opt "github.com/romnn/configo"
@@ -13,9 +13,11 @@ import (
 // Datasource ...
 type Datasource struct {
 	Options
-	Disabled         bool
-	Description      string
-	FileProvider     files.FileProvider
+	Disabled    bool
+	Description string
+	// FileProvider provides the input streams. The providers of this package implement it, and custom
+	// providers of local paths (files.FileProvider) are adapted with files.PathStreams
+	FileProvider     files.StreamProvider
 	bars             map[string]*uiprogress.Bar
 	totalProgressBar *uiprogress.Bar
 	owner            *Import
@@ -24,6 +26,8 @@ type Datasource struct {
 	totalFileCount   int64
 	doneFileCount    int64
 	result           SourceResult
+	resultMux        sync.Mutex
+	budget           *errorBudget
 }
 
 type progressHandler struct {
@@ -44,25 +48,29 @@ func (ph progressHandler) Write(p []byte) (n int, err error) {
 }
 
 // FileImportWillStart ...
-func (s *Datasource) fileImportWillStart(file *os.File) progressHandler {
+func (s *Datasource) fileImportWillStart(file string, size int64) progressHandler {
 	var handler progressHandler
 	var bar *uiprogress.Bar
 	s.owner.newProgressBarMux.Lock()
 	if opt.Enabled(s.Options.IndividualProgress) {
 		// Create a new progress bar
-		filename := filepath.Base(file.Name())
-		bar = uiprogress.AddBar(10).AppendCompleted()
-		bar.PrependFunc(s.owner.progressStatus(&filename, s.Collection))
-		if stats, err := file.Stat(); err == nil {
-			bar.Total = int(stats.Size())
+		filename := filepath.Base(file)
+		bar = uiprogress.AddBar(10)
+		if size >= 0 {
+			bar.Total = int(size)
+			bar.AppendCompleted()
 		}
+		bar.PrependFunc(s.owner.progressStatus(&filename, s.Collection, size >= 0))
 		go s.owner.updateLongestDescription(filename)
-		s.bars[file.Name()] = bar
+		s.bars[file] = bar
 	} else {
 		if s.totalProgressBar == nil {
 			s.updateDescription()
-			bar = uiprogress.AddBar(10).AppendCompleted()
-			bar.PrependFunc(s.owner.progressStatus(&s.description, s.Collection))
+			bar = uiprogress.AddBar(10)
+			if size >= 0 {
+				bar.AppendCompleted()
+			}
+			bar.PrependFunc(s.owner.progressStatus(&s.description, s.Collection, size >= 0))
 			s.totalProgressBar = bar
 			go func() {
 				// Update the progressbar total in batches
@@ -71,7 +79,7 @@ func (s *Datasource) fileImportWillStart(file *os.File) progressHandler {
 					// If there is no description for this
 					s.totalFileCount = interimFileCount
 					s.updateDescription()
-					if opt.Enabled(s.Options.ShowCurrentFile) && len(interimLongestFilename) > len(s.owner.longestDescription) {
+					if opt.Enabled(s.Options.ShowCurrentFile) {
 						go s.owner.updateLongestDescription(interimLongestFilename)
 					}
 				})
@@ -116,9 +124,8 @@ func (s *Datasource) updateDescription() {
 	if s.Description != "" {
 		s.description = fmt.Sprintf("%s (%s)", s.Description, s.description)
 	}
-	if len(s.description) > len(s.owner.longestDescription) {
-		go s.owner.updateLongestDescription(s.description)
-	}
+	// The longest description is only compared while it is locked
+	go s.owner.updateLongestDescription(s.description)
 }
 
 // PostLoadHook ...
@@ -128,8 +135,16 @@ type PostLoadHook func(loaded map[string]interface{}) ([]interface{}, error)
 type PreDumpHook func(loaded interface{}) ([]interface{}, error)
 
 // UpdateFilterHook ...
+//
+// Deprecated: Use WriteFilterHook, which is called for write modes other than insert
 type UpdateFilterHook func(loaded interface{}) ([]interface{}, error)
 
+// WriteFilterHook returns the filter that selects the existing document for write modes other than insert
+type WriteFilterHook func(doc interface{}) (interface{}, error)
+
+// PartialResultHook is called with the result of each file as soon as it was imported
+type PartialResultHook func(result PartialResult)
+
 func defaultPostLoad(loaded map[string]interface{}) ([]interface{}, error) {
 	return []interface{}{loaded}, nil
 }
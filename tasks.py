@@ -44,6 +44,47 @@ def test(c):
     c.run("env GO111MODULE=on go test -v -race ./...")
 
 
+@task
+def tls_certs(c):
+    """Create a self-signed CA with server and client certificates for testing TLS against a local mongod
+    """
+    tls_dir = BUILD_DIR.joinpath("tls")
+    tls_dir.mkdir(parents=True, exist_ok=True)
+    tls_dir.joinpath("server.ext").write_text("subjectAltName=DNS:localhost,IP:127.0.0.1\n")
+    with c.cd(str(tls_dir)):
+        c.run(
+            'openssl req -x509 -newkey rsa:2048 -nodes -days 30 -subj "/CN=mongoimport-ca" -keyout ca.key -out ca.pem'
+        )
+        for name, subject in [
+            ("server", "/O=mongoimport-server/CN=localhost"),
+            ("client", "/O=mongoimport/CN=importer"),
+        ]:
+            c.run(
+                'openssl req -newkey rsa:2048 -nodes -subj "{}" -keyout {}.key -out {}.csr'.format(
+                    subject, name, name
+                )
+            )
+            extfile = "-extfile server.ext" if name == "server" else ""
+            c.run(
+                "openssl x509 -req -in {}.csr -CA ca.pem -CAkey ca.key -CAcreateserial -days 30 {} -out {}.crt".format(
+                    name, extfile, name
+                )
+            )
+            c.run("cat {}.crt {}.key > {}.pem".format(name, name, name))
+    print("Start mongod with:")
+    print(
+        "  mongod --dbpath <dir> --tlsMode requireTLS --tlsCertificateKeyFile {0}/server.pem --tlsCAFile {0}/ca.pem".format(
+            tls_dir
+        )
+    )
+    print("Import with:")
+    print(
+        "  mongoimport --db-tls-ca-file {0}/ca.pem --db-tls-cert-file {0}/client.pem --db-auth-mechanism MONGODB-X509 ...".format(
+            tls_dir
+        )
+    )
+
+
 @task
 def cyclo(c):
     """Check code complexity
@@ -0,0 +1,46 @@
+package mongoimport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+func (c *MongoConnection) usesTLS() bool {
+	return c.TLS || c.TLSCAFile != "" || c.TLSCertificateFile != "" || c.TLSInsecure
+}
+
+// tlsConfig adds the TLS options of the connection to the TLS configuration of the connection URI
+func (c *MongoConnection) tlsConfig(base *tls.Config) (*tls.Config, error) {
+	config := &tls.Config{}
+	if base != nil {
+		config = base.Clone()
+	}
+	if c.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No PEM encoded certificates found in CA file %s", c.TLSCAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.TLSCertificateFile != "" {
+		keyFile := c.TLSKeyFile
+		if keyFile == "" {
+			keyFile = c.TLSCertificateFile
+		}
+		certificate, err := tls.LoadX509KeyPair(c.TLSCertificateFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	if c.TLSInsecure {
+		config.InsecureSkipVerify = true
+	}
+	return config, nil
+}
@@ -0,0 +1,103 @@
+package mongoimport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCertificate writes a self-signed certificate and its key to PEM files
+func writeSelfSignedCertificate(t *testing.T, dir string, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSConnectionOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile, _ := writeSelfSignedCertificate(t, dir, "ca")
+	certFile, keyFile := writeSelfSignedCertificate(t, dir, "client")
+
+	connection := &MongoConnection{
+		URI:                "mongodb://localhost:27017/?appName=importer",
+		AuthMechanism:      "MONGODB-X509",
+		TLSCAFile:          caFile,
+		TLSCertificateFile: certFile,
+		TLSKeyFile:         keyFile,
+	}
+	clientOptions, err := connection.ClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := clientOptions.TLSConfig
+	if config == nil {
+		t.Fatalf("Expected the CA file to enable TLS")
+	}
+	if config.RootCAs == nil || len(config.RootCAs.Subjects()) != 1 {
+		t.Errorf("Expected the certificate of the CA file to be trusted")
+	}
+	if len(config.Certificates) != 1 || config.InsecureSkipVerify {
+		t.Errorf("Expected the client certificate and verified server certificates")
+	}
+	if clientOptions.Auth == nil || clientOptions.Auth.AuthMechanism != "MONGODB-X509" {
+		t.Errorf("Expected X.509 authentication but got %+v", clientOptions.Auth)
+	}
+
+	// Options of the URI are kept
+	clientOptions, err = (&MongoConnection{URI: "mongodb://localhost/?tls=true&tlsAllowInvalidHostnames=true", TLSInsecure: true}).ClientOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientOptions.TLSConfig == nil || !clientOptions.TLSConfig.InsecureSkipVerify {
+		t.Errorf("Expected server certificates not to be verified")
+	}
+
+	if clientOptions, err := (&MongoConnection{}).ClientOptions(); err != nil || clientOptions.TLSConfig != nil {
+		t.Errorf("Expected no TLS by default (%v)", err)
+	}
+	if _, err := (&MongoConnection{TLSCertificateFile: filepath.Join(dir, "missing.pem")}).ClientOptions(); err == nil {
+		t.Errorf("Expected an error for a missing client certificate")
+	}
+	if _, err := (&MongoConnection{TLSCAFile: keyFile}).ClientOptions(); err == nil {
+		t.Errorf("Expected an error for a CA file without certificates")
+	}
+}
@@ -3,7 +3,6 @@ package mongoimport
 import (
 	"errors"
 	"fmt"
-	"os"
 
 	"github.com/gosuri/uiprogress"
 	"github.com/gosuri/uiprogress/util/strutil"
@@ -50,22 +49,29 @@ func (i *Import) safeLength() uint {
 }
 
 func (i *Import) formattedProgressStatus(description string, collection string, bytesDone string, bytesTotal string) string {
+	if bytesTotal == "" {
+		return fmt.Sprintf("[%s -> %s] %s", description, collection, bytesDone)
+	}
 	return fmt.Sprintf("[%s -> %s] %s/%s", description, collection, bytesDone, bytesTotal)
 }
 
-func (i *Import) progressStatus(description *string, collection string) func(b *uiprogress.Bar) string {
+// progressStatus only shows the bytes done for streams of unknown size such as stdin
+func (i *Import) progressStatus(description *string, collection string, totalKnown bool) func(b *uiprogress.Bar) string {
 	i.updateLongestDescriptionMux.Lock()
 	defer i.updateLongestDescriptionMux.Unlock()
 	return func(b *uiprogress.Bar) string {
 		bytesDone := byteCountSI(int64(b.Current()))
-		bytesTotal := byteCountSI(int64(b.Total))
+		var bytesTotal string
+		if totalKnown {
+			bytesTotal = byteCountSI(int64(b.Total))
+		}
 		status := i.formattedProgressStatus(*description, collection, bytesDone, bytesTotal)
 		return strutil.Resize(status, i.safeLength())
 	}
 }
 
 func (i *Import) sourceDatabaseName(source *Datasource) (string, error) {
-	databaseName := i.Connection.DatabaseName
+	databaseName := i.Connection.DefaultDatabase()
 	if source.DatabaseName != "" {
 		databaseName = source.DatabaseName
 	}
@@ -85,14 +91,3 @@ func (i *Import) sourceBatchSize(source *Datasource) int {
 	}
 	return defaultInsertionBatchSize
 }
-
-func openFile(file string) (*os.File, error) {
-	if file == "" {
-		return nil, errors.New("Got invalid empty file path")
-	}
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	return f, nil
-}
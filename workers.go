@@ -1,13 +1,19 @@
 package mongoimport
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
 	"io"
+	"io/ioutil"
 	"sync"
 	"time"
 
 	opt "github.com/romnn/configo"
+	"github.com/romnn/mongoimport/files"
 	"github.com/romnn/mongoimport/loaders"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -16,9 +22,14 @@ type ImportJob struct {
 	Source             *Datasource
 	Loader             *loaders.Loader
 	File               string
+	Stream             files.Stream
 	InsertionBatchSize int
 	IgnoreErrors       bool
 	Collection         *mongo.Collection
+	Ledger             Ledger
+	LedgerEntry        *LedgerEntry
+	Checkpoints        CheckpointStore
+	ResumeRecords      int
 }
 
 func (i *Import) produceJobs(jobChan chan ImportJob) error {
@@ -28,57 +39,131 @@ func (i *Import) produceJobs(jobChan chan ImportJob) error {
 			return err
 		}
 	}
+	var producerWg sync.WaitGroup
+	for _, s := range i.sources {
+		producerWg.Add(1)
+		// Sources are produced concurrently so that a watched source does not block the others
+		go func(s *Datasource) {
+			defer producerWg.Done()
+			i.produceSourceJobs(s, jobChan)
+		}(s)
+	}
 	go func() {
-		for _, s := range i.sources {
-			for {
-				file, err := s.FileProvider.NextFile()
-				partialResult := PartialResult{
-					File:       file,
-					Source:     s,
-					Collection: s.Collection,
-				}
-				if err == io.EOF {
-					// No-op (produced all files for this source)
-					break
-				} else if err != nil {
-					partialResult.Errors = append(partialResult.Errors, err)
-					s.result.PartialResults = append(s.result.PartialResults, partialResult)
-					log.Warn(err)
-				} else {
-					dbName, err := i.sourceDatabaseName(s)
-					if err != nil {
-						partialResult.Errors = append(partialResult.Errors, err)
-						s.result.PartialResults = append(s.result.PartialResults, partialResult)
-						continue
-					}
-					db := i.dbClient.Database(dbName)
-					collection := db.Collection(s.Collection)
-					jobChan <- ImportJob{
-						Source:             s,
-						File:               file,
-						Loader:             &s.Loader,
-						IgnoreErrors:       opt.Enabled(i.Options.FailOnErrors),
-						InsertionBatchSize: i.sourceBatchSize(s),
-						Collection:         collection,
-					}
-					log.Debugf("produced %s", file)
-				}
-			}
-		}
+		producerWg.Wait()
 		log.Debug("done producing jobs")
 		close(jobChan)
 	}()
 	return nil
 }
 
+func (i *Import) produceSourceJobs(s *Datasource, jobChan chan<- ImportJob) {
+	for !i.isAborted() {
+		stream, err := s.FileProvider.NextStream()
+		partialResult := PartialResult{
+			Source:     s,
+			Collection: s.Collection,
+		}
+		if err == io.EOF {
+			// No-op (produced all files for this source)
+			break
+		} else if err != nil {
+			partialResult.Errors = append(partialResult.Errors, err)
+			s.addPartialResult(partialResult)
+			log.Warn(err)
+		} else {
+			file := stream.Name()
+			partialResult.File = file
+			var entry *LedgerEntry
+			if i.Ledger != nil {
+				entry = newLedgerEntry(stream, s.Collection)
+				skip, err := i.alreadyImported(entry, stream)
+				if err != nil {
+					log.Warnf("Failed to check if %s was already imported: %v", file, err)
+				} else if skip {
+					log.Infof("Skipping %s because it was already imported", file)
+					s.skipFile()
+					continue
+				}
+			}
+			dbName, err := i.sourceDatabaseName(s)
+			if err != nil {
+				partialResult.Errors = append(partialResult.Errors, err)
+				s.addPartialResult(partialResult)
+				continue
+			}
+			var resumeRecords int
+			if i.Checkpoints != nil && opt.Enabled(s.Options.Resume) {
+				checkpoint, err := i.Checkpoints.Load(s.Collection, file)
+				if err != nil {
+					log.Warnf("Failed to load the checkpoint of %s: %v", file, err)
+				} else if checkpoint != nil && !checkpoint.matches(stream.Size()) {
+					log.Warnf("Importing %s from the beginning because it changed since the last checkpoint", file)
+				} else if checkpoint != nil && checkpoint.Completed {
+					log.Infof("Skipping %s because it was imported completely", file)
+					s.skipFile()
+					continue
+				} else if checkpoint != nil {
+					resumeRecords = checkpoint.Records
+				}
+			}
+			db := i.dbClient.Database(dbName)
+			collection := db.Collection(s.Collection, s.collectionOptions())
+			job := ImportJob{
+				Source:             s,
+				File:               file,
+				Stream:             stream,
+				Loader:             &s.Loader,
+				IgnoreErrors:       opt.Enabled(i.Options.FailOnErrors),
+				InsertionBatchSize: i.sourceBatchSize(s),
+				Collection:         collection,
+				Ledger:             i.Ledger,
+				LedgerEntry:        entry,
+				Checkpoints:        i.Checkpoints,
+				ResumeRecords:      resumeRecords,
+			}
+			select {
+			case jobChan <- job:
+				log.Debugf("produced %s", file)
+			case <-i.aborted:
+				return
+			}
+		}
+	}
+}
+
+// addPartialResult adds the result of a file that could not be imported
+func (s *Datasource) addPartialResult(partial PartialResult) {
+	s.resultMux.Lock()
+	defer s.resultMux.Unlock()
+	s.result.PartialResults = append(s.result.PartialResults, partial)
+}
+
+func (s *Datasource) skipFile() {
+	s.resultMux.Lock()
+	defer s.resultMux.Unlock()
+	s.result.Skipped++
+}
+
+func (i *Import) alreadyImported(entry *LedgerEntry, stream files.Stream) (bool, error) {
+	previous, err := i.Ledger.Lookup(entry.Collection, entry.File)
+	if err != nil {
+		return false, err
+	}
+	return entry.unchanged(previous, stream)
+}
+
 func (i *Import) consumeJobs(wg *sync.WaitGroup, jobChan <-chan ImportJob, producerDoneChan chan bool, resultsChan chan<- PartialResult) error {
+	var insertWg sync.WaitGroup
+	i.startInserters(&insertWg, i.inserts)
 	for w := 1; w <= i.MaxParallelism; w++ {
 		wg.Add(1)
 		go worker(w, wg, jobChan, producerDoneChan, resultsChan)
 	}
 	go func() {
-		// Wait for all workers to finish before closing the results channel
+		// Workers wait for the batches of their files, so the inserters are idle once all workers finished
 		wg.Wait()
+		close(i.inserts)
+		insertWg.Wait()
 		close(resultsChan)
 	}()
 	return nil
@@ -87,6 +172,10 @@ func (i *Import) consumeJobs(wg *sync.WaitGroup, jobChan <-chan ImportJob, produ
 func worker(id int, wg *sync.WaitGroup, jobChan <-chan ImportJob, producerDoneChan chan bool, resultsChan chan<- PartialResult) {
 	defer wg.Done()
 	for j := range jobChan {
+		if j.Source.owner.isAborted() {
+			// Remaining jobs are drained without importing them
+			continue
+		}
 		log.Debugf("worker %d started job %v", id, j)
 		j.Source.currentFile = j.File
 		j.Source.updateDescription()
@@ -97,6 +186,64 @@ func worker(id int, wg *sync.WaitGroup, jobChan <-chan ImportJob, producerDoneCh
 	log.Debugf("worker %d exited", id)
 }
 
+type loadResult struct {
+	entry    map[string]interface{}
+	err      error
+	position loaders.Position
+	// raw is the raw input of records that could not be loaded
+	raw string
+	// document holds the field order of the entry if the loader keeps it
+	document bson.D
+}
+
+// recordSource is the provenance of a batched document
+type recordSource struct {
+	// record is the 0-based index of the record in the file
+	record   int
+	position loaders.Position
+}
+
+func (source recordSource) error(file string, err error) *RecordError {
+	return &RecordError{
+		File:   file,
+		Record: source.record + 1,
+		Line:   source.position.Line,
+		Offset: source.position.Offset,
+		Err:    err,
+	}
+}
+
+// loadEntries loads entries in the background until the loader returns io.EOF or done is closed
+func loadEntries(loader *loaders.Loader, done <-chan struct{}) <-chan loadResult {
+	entries := make(chan loadResult)
+	go func() {
+		defer close(entries)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			entry, err := loader.Load()
+			result := loadResult{entry: entry, err: err, position: loader.Position()}
+			if err == nil {
+				result.document = loader.Document()
+			} else if err != io.EOF {
+				result.raw = loader.Raw()
+			}
+			select {
+			case entries <- result:
+			case <-done:
+				return
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}()
+	return entries
+}
+
 func (s *Datasource) process(job ImportJob) PartialResult {
 	start := time.Now()
 	result := PartialResult{
@@ -106,18 +253,33 @@ func (s *Datasource) process(job ImportJob) PartialResult {
 	}
 
 	// Open File
-	file, err := openFile(job.File)
-	defer file.Close()
+	file, err := job.Stream.Open()
 	if err != nil {
 		result.Errors = append(result.Errors, err)
 		return result
 	}
+	defer file.Close()
+
+	// Apply collection options and indexes of mongodump output before inserting
+	if opt.Enabled(s.Options.RestoreMetadata) {
+		if err := restoreMetadataIfPresent(job.Collection, job.File); err != nil {
+			log.Warn(err)
+			result.Errors = append(result.Errors, err)
+		}
+	}
 
 	// Start progress bar
-	updateHandler := s.fileImportWillStart(file)
+	updateHandler := s.fileImportWillStart(job.File, job.Stream.Size())
+
+	// The content of files recorded in the ledger is hashed while it is imported
+	var input io.Reader = file
+	hasher := sha256.New()
+	if job.LedgerEntry != nil {
+		input = io.TeeReader(file, hasher)
+	}
 
 	// Create a new loader for each file here
-	loader, err := job.Loader.Create(file, updateHandler)
+	loader, err := job.Loader.Create(input, updateHandler)
 	if err != nil {
 		result.Errors = append(result.Errors, err)
 		return result
@@ -125,89 +287,175 @@ func (s *Datasource) process(job ImportJob) PartialResult {
 
 	loader.Start()
 
-	var batch []interface{}
-	batched := 0
+	// Fast-forward to the first record that was not inserted before the import was interrupted
+	records := 0
+	for records < job.ResumeRecords {
+		if _, err := loader.Load(); err == io.EOF {
+			break
+		}
+		records++
+	}
+	if records > 0 {
+		log.Infof("Resuming %s after %d records", job.File, records)
+	}
+
+	// Full batches are written by the inserters while the next records are loaded
+	writes := newFileWrites(job, s.owner.inserts)
+	var batch []mongo.WriteModel
+	// sources holds the record each batched document was loaded from
+	var sources []recordSource
+
+	// Partial batches are flushed once no new entries were loaded for the flush interval (e.g. when following a file)
+	var flush <-chan time.Time
+	if s.Options.FlushInterval > 0 {
+		ticker := time.NewTicker(s.Options.FlushInterval / 2)
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+	// Succeeded and failed records are counted towards the error limits as they are processed
+	var counted PartialResult
+	countRecords := func() {
+		s.countRecords(result.Succeeded-counted.Succeeded, result.Failed-counted.Failed)
+		counted.Succeeded, counted.Failed = result.Succeeded, result.Failed
+	}
+
+	lastLoaded := time.Now()
+	done := make(chan struct{})
+	entries := loadEntries(loader, done)
+	aborted := false
+	completed := false
 	for {
-		exit := false
-		entry, err := loader.Load()
-		if err != nil {
-			switch err {
-			case io.EOF:
-				exit = true
-			default:
-				result.Failed++
-				result.Errors = append(result.Errors, err)
-				if opt.Enabled(s.Options.FailOnErrors) {
-					log.Errorf(err.Error())
-					break
-				} else {
-					log.Warnf(err.Error())
-					continue
-				}
+		countRecords()
+		if s.owner.isAborted() {
+			if len(batch) > 0 && s.abortPolicy() == DiscardOnAbort {
+				log.Warnf("Discarding %d documents of %s", len(batch), job.File)
+				// The discarded documents are imported again when resuming
+				records = sources[0].record
+			} else {
+				writes.enqueue(batch, sources, records)
 			}
+			aborted = true
+			break
 		}
-
-		if exit {
-			// Insert remaining
-			err := insert(job.Collection, batch[:batched])
-			if err != nil {
-				log.Warn(err)
-				result.Errors = append(result.Errors, err)
+		var next loadResult
+		select {
+		case <-s.owner.aborted:
+			continue
+		case next = <-entries:
+			lastLoaded = time.Now()
+		case <-flush:
+			if len(batch) > 0 && time.Since(lastLoaded) >= s.Options.FlushInterval {
+				writes.enqueue(batch, sources, records)
+				batch = nil
+				sources = nil
 			}
-			result.Succeeded += batched
+			continue
+		}
+		entry, err := next.entry, next.err
+		if err == io.EOF {
+			// Insert remaining
+			writes.enqueue(batch, sources, records)
+			completed = true
 			break
 		}
+		records++
+		source := recordSource{record: records - 1, position: next.position}
+		if err != nil {
+			s.reject(job, LoadStage, source, next.raw, err)
+			result.Failed++
+			result.Errors = append(result.Errors, err)
+			log.Warnf(err.Error())
+			continue
+		}
 
 		// Apply post load hook
 		loaded, err := s.PostLoad(entry)
 		if err != nil {
 			log.Error(err)
+			s.reject(job, PostLoadStage, source, entry, err)
 			result.Failed++
 			continue
 		}
 
-		// var dumped []interface{}
 		for _, l := range loaded {
 			// Apply pre dump hook
 			d, err := s.PreDump(l)
 			if err != nil {
 				log.Error(err)
+				s.reject(job, PreDumpStage, source, l, err)
 				result.Failed++
 				continue
 			}
-			batch = append(batch, d...)
-			batched += len(d)
+			for _, doc := range d {
+				model, err := s.writeModel(doc, next.document)
+				if err != nil {
+					log.Error(err)
+					s.reject(job, WriteStage, source, doc, err)
+					result.Failed++
+					continue
+				}
+				batch = append(batch, model)
+				sources = append(sources, source)
+			}
 		}
 
 		// Flush batch
-		for batched >= job.InsertionBatchSize {
-			minibatch := batch[:job.InsertionBatchSize]
-			// 	if updateFilter != nil {
-			// 		database.Collection(collection).UpdateMany(
-			// 			context.Background(),
-			// 			updateFilter(dumped), update, options.Update().SetUpsert(true),
-			// 		)
-			// 	}
-
-			// database.Collection(collection).InsertMany(context.Background(), batch)
-			// filter := bson.D{{}}
-			// update := batch // []interface{}
-			// options := options.UpdateOptions{}
-			// options.se
-			// log.Infof("insert into %s:%s", databaseName, collection)
-			err := insert(job.Collection, minibatch)
-			if err != nil {
-				log.Warn(err)
-				result.Errors = append(result.Errors, err)
-				break
+		for len(batch) >= job.InsertionBatchSize {
+			size := job.InsertionBatchSize
+			// The record of the first remaining document was not batched completely
+			batchedRecords := records
+			if len(batch) > size {
+				batchedRecords = sources[size].record
 			}
-			result.Succeeded += len(minibatch)
-			batched -= len(minibatch)
-			batch = batch[len(minibatch):]
+			writes.enqueue(batch[:size:size], sources[:size:size], batchedRecords)
+			batch = batch[size:]
+			sources = sources[size:]
 		}
 	}
+	countRecords()
+	result.add(writes.finish(records, completed))
+	// The loader is only finished once it stopped loading in the background
+	close(done)
+	for range entries {
+	}
 	loader.Finish()
-	s.fileImportDidComplete(file.Name())
+	if job.LedgerEntry != nil && !aborted && len(result.Errors) == 0 && result.Failed == 0 {
+		if err := s.recordImport(job, input, hasher); err != nil {
+			log.Warn(err)
+			result.Errors = append(result.Errors, err)
+		}
+	}
+	s.fileImportDidComplete(job.File)
 	result.Elapsed = time.Since(start)
 	return result
 }
+
+// saveCheckpoint records the number of records whose documents were all inserted
+func (s *Datasource) saveCheckpoint(job ImportJob, records int, completed bool) {
+	if job.Checkpoints == nil {
+		return
+	}
+	err := job.Checkpoints.Save(Checkpoint{
+		File:       job.File,
+		Collection: s.Collection,
+		Size:       job.Stream.Size(),
+		Records:    records,
+		Completed:  completed,
+		UpdatedAt:  time.Now(),
+	})
+	if err != nil {
+		log.Warn(err)
+	}
+}
+
+// recordImport adds a successfully imported file to the ledger
+func (s *Datasource) recordImport(job ImportJob, input io.Reader, hasher hash.Hash) error {
+	// Loaders might not read trailing bytes of the file that are still needed for the hash
+	if _, err := io.Copy(ioutil.Discard, input); err != nil {
+		return err
+	}
+	entry := *job.LedgerEntry
+	entry.Hash = hex.EncodeToString(hasher.Sum(nil))
+	entry.ImportedAt = time.Now()
+	return job.Ledger.Record(entry)
+}
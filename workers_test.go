@@ -0,0 +1,108 @@
+package mongoimport
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gosuri/uiprogress"
+	opt "github.com/romnn/configo"
+	"github.com/romnn/mongoimport/files"
+	"github.com/romnn/mongoimport/loaders"
+)
+
+type failingProvider struct {
+	failures int
+}
+
+func (p *failingProvider) Prepare() error                               { return nil }
+func (p *failingProvider) FetchDirMetadata(files.MetadataUpdateHandler) {}
+func (p *failingProvider) NextStream() (files.Stream, error) {
+	if p.failures <= 0 {
+		return nil, io.EOF
+	}
+	p.failures--
+	return nil, errors.New("Unavailable")
+}
+
+func TestProducerErrorsWhileCollecting(t *testing.T) {
+	i := &Import{aborted: make(chan struct{})}
+	source := &Datasource{FileProvider: &failingProvider{failures: 100}, owner: i, Options: Options{Collection: "people", IndividualProgress: opt.SetFlag(true)}}
+	i.sources = []*Datasource{source}
+
+	jobs := make(chan ImportJob)
+	produced := make(chan struct{})
+	go func() {
+		defer close(produced)
+		i.produceSourceJobs(source, jobs)
+	}()
+	// Results of imported files are collected while the producer adds its errors
+	var result ImportResult
+	for n := 0; n < 100; n++ {
+		i.collect(&result, PartialResult{Source: source, Collection: "people", Succeeded: 1})
+	}
+	<-produced
+
+	if result.TotalFiles != 100 || result.Succeeded != 100 {
+		t.Errorf("Expected 100 collected files but got %d files with %d records", result.TotalFiles, result.Succeeded)
+	}
+	if len(source.result.PartialResults) != 200 {
+		t.Errorf("Expected the results of 100 imported and 100 failed files but got %d", len(source.result.PartialResults))
+	}
+}
+
+// closingReader fails reads after it was closed like a file
+type closingReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closingReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, errors.New("Read after close")
+	}
+	return r.Reader.Read(p)
+}
+
+func (r *closingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestAbortWhileLoading(t *testing.T) {
+	// Records are larger than the read buffer so that every record is read from the file
+	data := "name\n" + strings.Repeat(strings.Repeat("a", 8192)+"\n", 100)
+
+	i := &Import{aborted: make(chan struct{})}
+	loaded := 0
+	source := &Datasource{
+		FileProvider: &failingProvider{},
+		owner:        i,
+		bars:         make(map[string]*uiprogress.Bar),
+		Options:      Options{Collection: "people", OnAbort: DiscardOnAbort},
+	}
+	source.PostLoad = func(entry map[string]interface{}) ([]interface{}, error) {
+		if loaded++; loaded == 10 {
+			i.abort(errors.New("Aborted"))
+		}
+		return []interface{}{entry}, nil
+	}
+	source.prepareHooks()
+	i.sources = []*Datasource{source}
+
+	job := ImportJob{
+		Source: source,
+		Loader: &loaders.Loader{SpecificLoader: &loaders.CSVLoader{Delimiter: ","}},
+		File:   "people.csv",
+		Stream: files.NewStream("people.csv", int64(len(data)), func() (io.ReadCloser, error) {
+			return &closingReader{Reader: strings.NewReader(data)}, nil
+		}),
+		InsertionBatchSize: 1000,
+	}
+	// The file must not be closed while the next record is loaded in the background
+	result := source.process(job)
+	if loaded != 10 || result.Succeeded != 0 {
+		t.Errorf("Expected the import to stop after 10 discarded records but %d records were loaded and %d succeeded", loaded, result.Succeeded)
+	}
+}
@@ -0,0 +1,467 @@
+package mongoimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	opt "github.com/romnn/configo"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// WriteMode controls how loaded documents are written to the collection
+type WriteMode string
+
+const (
+	// InsertMode inserts every document
+	InsertMode WriteMode = "insert"
+	// UpsertMode replaces the document with the same keys or inserts it if there is none
+	UpsertMode WriteMode = "upsert"
+	// ReplaceMode replaces the document with the same keys and skips documents without a match
+	ReplaceMode WriteMode = "replace"
+	// MergeMode sets the fields of the document with the same keys or inserts it if there is none
+	MergeMode WriteMode = "merge"
+)
+
+// ParseWriteMode ...
+func ParseWriteMode(mode string) (WriteMode, error) {
+	switch WriteMode(strings.ToLower(mode)) {
+	case "", InsertMode:
+		return InsertMode, nil
+	case UpsertMode:
+		return UpsertMode, nil
+	case ReplaceMode:
+		return ReplaceMode, nil
+	case MergeMode:
+		return MergeMode, nil
+	}
+	return "", fmt.Errorf("Unknown write mode %s (must be one of insert, upsert, replace or merge)", mode)
+}
+
+func (s *Datasource) duplicatePolicy() DuplicatePolicy {
+	if s.Options.OnDuplicate == "" {
+		return FailOnDuplicate
+	}
+	return s.Options.OnDuplicate
+}
+
+func (s *Datasource) writeMode() WriteMode {
+	if s.Options.WriteMode == "" {
+		return InsertMode
+	}
+	return s.Options.WriteMode
+}
+
+// validateWriteMode checks that documents can be matched by their keys if they are not inserted
+func (s *Datasource) validateWriteMode() error {
+	mode, err := ParseWriteMode(string(s.Options.WriteMode))
+	if err != nil {
+		return err
+	}
+	if _, err := ParseDuplicatePolicy(string(s.Options.OnDuplicate)); err != nil {
+		return err
+	}
+	if mode != InsertMode && len(s.Options.UpsertFields) == 0 && s.Options.WriteFilter == nil {
+		return fmt.Errorf("Write mode %s for collection %s requires upsert fields or a write filter", mode, s.Collection)
+	}
+	return nil
+}
+
+// updateFilter selects the existing document using the write filter hook or the values of the upsert fields
+func (s *Datasource) updateFilter(doc interface{}) (interface{}, error) {
+	if s.Options.WriteFilter != nil {
+		return s.Options.WriteFilter(doc)
+	}
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.D{}
+	for _, field := range s.Options.UpsertFields {
+		value, err := bson.Raw(raw).LookupErr(strings.Split(field, ".")...)
+		if err != nil {
+			return nil, fmt.Errorf("Missing upsert field %s in document %v", field, doc)
+		}
+		filter = append(filter, bson.E{Key: field, Value: value})
+	}
+	return filter, nil
+}
+
+// writeModel creates the write operation of a document for the write mode of the source.
+// The fields are written in the order of the loaded document if the loader keeps it
+func (s *Datasource) writeModel(doc interface{}, order bson.D) (mongo.WriteModel, error) {
+	mode := s.writeMode()
+	if mode == InsertMode {
+		return mongo.NewInsertOneModel().SetDocument(orderedValue(doc, order)), nil
+	}
+	filter, err := s.updateFilter(doc)
+	if err != nil {
+		return nil, err
+	}
+	doc = orderedValue(doc, order)
+	switch mode {
+	case UpsertMode:
+		return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc).SetUpsert(true), nil
+	case ReplaceMode:
+		return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc), nil
+	case MergeMode:
+		return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{"$set": doc}).SetUpsert(true), nil
+	}
+	return nil, fmt.Errorf("Unknown write mode %s", mode)
+}
+
+// orderedValue restores the field order of the original value in documents that were converted to maps.
+// Fields that were added by hooks follow in alphabetical order
+func orderedValue(value interface{}, original interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		order, ok := original.(bson.D)
+		if !ok || order == nil {
+			return v
+		}
+		ordered := make(bson.D, 0, len(v))
+		for _, e := range order {
+			if child, ok := v[e.Key]; ok {
+				ordered = append(ordered, bson.E{Key: e.Key, Value: orderedValue(child, e.Value)})
+			}
+		}
+		var added []string
+		for key := range v {
+			if !hasKey(order, key) {
+				added = append(added, key)
+			}
+		}
+		sort.Strings(added)
+		for _, key := range added {
+			ordered = append(ordered, bson.E{Key: key, Value: v[key]})
+		}
+		return ordered
+	case []interface{}:
+		elements, ok := original.(primitive.A)
+		if !ok {
+			return v
+		}
+		ordered := make([]interface{}, len(v))
+		for i, child := range v {
+			if i < len(elements) {
+				child = orderedValue(child, elements[i])
+			}
+			ordered[i] = child
+		}
+		return ordered
+	default:
+		return v
+	}
+}
+
+func hasKey(doc bson.D, key string) bool {
+	for _, e := range doc {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// bulkWrite writes a batch of write operations
+func bulkWrite(ctx context.Context, collection *mongo.Collection, batch []mongo.WriteModel, opts *options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	if len(batch) < 1 {
+		return &mongo.BulkWriteResult{}, nil
+	}
+	return collection.BulkWrite(ctx, batch, opts)
+}
+
+// DuplicatePolicy controls how documents that violate a unique index are handled
+type DuplicatePolicy string
+
+const (
+	// FailOnDuplicate counts duplicates as failed and reports them as errors
+	FailOnDuplicate DuplicatePolicy = "fail"
+	// SkipDuplicates ignores documents that already exist
+	SkipDuplicates DuplicatePolicy = "skip"
+	// OverwriteDuplicates replaces the existing documents with the same values of the violated unique index
+	OverwriteDuplicates DuplicatePolicy = "overwrite"
+	// CollectDuplicates counts duplicates as failed and collects them in the result instead of reporting errors
+	CollectDuplicates DuplicatePolicy = "collect"
+)
+
+// ParseDuplicatePolicy ...
+func ParseDuplicatePolicy(policy string) (DuplicatePolicy, error) {
+	switch DuplicatePolicy(strings.ToLower(policy)) {
+	case "", FailOnDuplicate:
+		return FailOnDuplicate, nil
+	case SkipDuplicates:
+		return SkipDuplicates, nil
+	case OverwriteDuplicates:
+		return OverwriteDuplicates, nil
+	case CollectDuplicates:
+		return CollectDuplicates, nil
+	}
+	return "", fmt.Errorf("Unknown duplicate policy %s (must be one of fail, skip, overwrite or collect)", policy)
+}
+
+func isDuplicateKeyError(code int) bool {
+	return code == 11000 || code == 11001 || code == 12582
+}
+
+var duplicateIndexPattern = regexp.MustCompile(`index: (\S+) dup key`)
+
+// writeBatch writes the batch and counts every write as succeeded or failed.
+// Failed writes are reported with the source record of the document.
+// It returns false if documents were not attempted or might not have been written, which must be written again when resuming
+func (s *Datasource) writeBatch(ctx context.Context, job ImportJob, batch []mongo.WriteModel, sources []recordSource, policy DuplicatePolicy, result *PartialResult) bool {
+	written, err := bulkWrite(ctx, job.Collection, batch, s.bulkWriteOptions())
+	result.addWriteResult(written)
+	if err == nil {
+		result.Succeeded += len(batch)
+		return true
+	}
+	bulkErr, ok := err.(mongo.BulkWriteException)
+	if !ok {
+		// None of the documents were written
+		s.failBatch(job, batch, sources, err, result)
+		return false
+	}
+	complete := true
+	// The unique indexes are listed once for all duplicates that are overwritten
+	var indexes map[string][]string
+	var indexesErr error
+	lookupIndex := func(name string) ([]string, error) {
+		if indexes == nil && indexesErr == nil {
+			indexes, indexesErr = indexFields(ctx, job.Collection)
+		}
+		if indexesErr != nil {
+			return nil, indexesErr
+		}
+		fields, ok := indexes[name]
+		if !ok {
+			return nil, fmt.Errorf("Unique index %s does not exist", name)
+		}
+		return fields, nil
+	}
+	var overwrites []mongo.WriteModel
+	var overwriteSources []recordSource
+	for _, writeErr := range bulkErr.WriteErrors {
+		source := sources[writeErr.Index]
+		if !isDuplicateKeyError(writeErr.Code) {
+			result.addRecordError(source.error(job.File, writeErr.WriteError))
+			s.reject(job, WriteStage, source, writeDocument(batch[writeErr.Index]), writeErr.WriteError)
+			result.Failed++
+			continue
+		}
+		result.Duplicates++
+		switch policy {
+		case SkipDuplicates:
+			log.Debug(source.error(job.File, writeErr.WriteError))
+		case CollectDuplicates:
+			result.Failed++
+			result.addDuplicateDocuments(writeDocument(batch[writeErr.Index]))
+			s.reject(job, WriteStage, source, writeDocument(batch[writeErr.Index]), writeErr.WriteError)
+		case OverwriteDuplicates:
+			overwrite, err := overwriteModel(batch[writeErr.Index], writeErr.Message, lookupIndex)
+			if err != nil {
+				err = fmt.Errorf("Failed to overwrite duplicate: %v", err)
+				result.addRecordError(source.error(job.File, err))
+				s.reject(job, WriteStage, source, writeDocument(batch[writeErr.Index]), err)
+				result.Failed++
+				continue
+			}
+			overwrites = append(overwrites, overwrite)
+			overwriteSources = append(overwriteSources, source)
+		default:
+			result.addRecordError(source.error(job.File, writeErr.WriteError))
+			s.reject(job, WriteStage, source, writeDocument(batch[writeErr.Index]), writeErr.WriteError)
+			result.Failed++
+		}
+	}
+	if bulkErr.WriteConcernError != nil {
+		// The documents were written but the write concern was not satisfied, so they might not be durable
+		err := fmt.Errorf("Write concern error: %s", bulkErr.WriteConcernError.Message)
+		log.Warn(err)
+		result.Errors = append(result.Errors, err)
+		complete = false
+	}
+	attempted := len(batch)
+	if opt.Enabled(s.Options.WriteOptions.Ordered) && len(bulkErr.WriteErrors) > 0 {
+		// Ordered bulk writes stop at the first failed write
+		attempted = bulkErr.WriteErrors[len(bulkErr.WriteErrors)-1].Index + 1
+	}
+	result.Succeeded += attempted - len(bulkErr.WriteErrors)
+	if len(overwrites) > 0 {
+		// Duplicates of the overwrites are not overwritten again
+		complete = s.writeBatch(ctx, job, overwrites, overwriteSources, FailOnDuplicate, result) && complete
+	}
+	if attempted < len(batch) {
+		remaining, remainingSources := batch[attempted:], sources[attempted:]
+		stop := bulkErr.WriteErrors[len(bulkErr.WriteErrors)-1]
+		if isDuplicateKeyError(stop.Code) && policy != FailOnDuplicate {
+			// Handled duplicates do not stop the remaining writes
+			return s.writeBatch(ctx, job, remaining, remainingSources, policy, result) && complete
+		}
+		err := fmt.Errorf("%d documents of %s were not written because the ordered write stopped at a failed write", len(remaining), job.File)
+		s.failBatch(job, remaining, remainingSources, err, result)
+		return false
+	}
+	return complete
+}
+
+// failBatch counts the documents of a batch that were not written as failed
+func (s *Datasource) failBatch(job ImportJob, batch []mongo.WriteModel, sources []recordSource, err error, result *PartialResult) {
+	log.Warn(err)
+	result.Errors = append(result.Errors, err)
+	result.Failed += len(batch)
+	for idx, model := range batch {
+		s.reject(job, WriteStage, sources[idx], writeDocument(model), err)
+	}
+}
+
+// writeDocument returns the document of an insert, replace or merge
+func writeDocument(model mongo.WriteModel) interface{} {
+	switch m := model.(type) {
+	case *mongo.InsertOneModel:
+		return m.Document
+	case *mongo.ReplaceOneModel:
+		return m.Replacement
+	case *mongo.UpdateOneModel:
+		if update, ok := m.Update.(bson.M); ok {
+			return update["$set"]
+		}
+		return m.Update
+	}
+	return nil
+}
+
+// overwriteModel replaces the document that has the same values for the fields of the violated unique index
+func overwriteModel(model mongo.WriteModel, message string, lookupIndex func(name string) ([]string, error)) (mongo.WriteModel, error) {
+	match := duplicateIndexPattern.FindStringSubmatch(message)
+	if match == nil {
+		return nil, fmt.Errorf("Unknown unique index in %q", message)
+	}
+	fields, err := lookupIndex(match[1])
+	if err != nil {
+		return nil, err
+	}
+	doc := writeDocument(model)
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.D{}
+	for _, field := range fields {
+		// Missing fields are indexed as null
+		value, err := bson.Raw(raw).LookupErr(strings.Split(field, ".")...)
+		if err != nil {
+			filter = append(filter, bson.E{Key: field, Value: nil})
+			continue
+		}
+		filter = append(filter, bson.E{Key: field, Value: value})
+	}
+	if update, ok := model.(*mongo.UpdateOneModel); ok {
+		return mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update.Update), nil
+	}
+	return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(doc), nil
+}
+
+// indexFields returns the fields of the indexes of a collection by their names
+func indexFields(ctx context.Context, collection *mongo.Collection) (map[string][]string, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	indexes := make(map[string][]string)
+	for cursor.Next(ctx) {
+		var index struct {
+			Name string `bson:"name"`
+			Key  bson.D `bson:"key"`
+		}
+		if err := cursor.Decode(&index); err != nil {
+			return nil, err
+		}
+		var fields []string
+		for _, key := range index.Key {
+			fields = append(fields, key.Key)
+		}
+		indexes[index.Name] = fields
+	}
+	return indexes, cursor.Err()
+}
+
+// WriteOptions configures how batches are written
+type WriteOptions struct {
+	// W is the number of members that must acknowledge writes, "majority" or the name of a tag set
+	W string
+	// Journal requires writes to be written to the on-disk journal before they are acknowledged
+	Journal *opt.Flag
+	// WTimeout limits the time to wait for the acknowledgement of writes
+	WTimeout time.Duration
+	// Ordered stops writing a batch at the first failed write, which is unordered by default
+	Ordered                  *opt.Flag
+	BypassDocumentValidation *opt.Flag
+}
+
+// writeConcern returns nil if the default write concern of the server should be used
+func (w WriteOptions) writeConcern() *writeconcern.WriteConcern {
+	var concern []writeconcern.Option
+	if w.W != "" {
+		if w.W == "majority" {
+			concern = append(concern, writeconcern.WMajority())
+		} else if n, err := strconv.Atoi(w.W); err == nil {
+			concern = append(concern, writeconcern.W(n))
+		} else {
+			concern = append(concern, writeconcern.WTagSet(w.W))
+		}
+	}
+	if opt.FlagSet(w.Journal) {
+		concern = append(concern, writeconcern.J(opt.Enabled(w.Journal)))
+	}
+	if w.WTimeout > 0 {
+		concern = append(concern, writeconcern.WTimeout(w.WTimeout))
+	}
+	if len(concern) < 1 {
+		return nil
+	}
+	return writeconcern.New(concern...)
+}
+
+func (s *Datasource) validateWriteOptions() error {
+	w := s.Options.WriteOptions
+	if n, err := strconv.Atoi(w.W); err == nil && n < 0 {
+		return fmt.Errorf("Write concern w must not be negative but is %d", n)
+	}
+	if w.WTimeout < 0 {
+		return fmt.Errorf("Write concern timeout must not be negative but is %s", w.WTimeout)
+	}
+	if concern := w.writeConcern(); concern != nil && !concern.IsValid() {
+		return errors.New("Unacknowledged writes (w: 0) can not be journaled")
+	}
+	return nil
+}
+
+// collectionOptions applies the write concern of the source to its collection
+func (s *Datasource) collectionOptions() *options.CollectionOptions {
+	collectionOptions := options.Collection()
+	if concern := s.Options.WriteOptions.writeConcern(); concern != nil {
+		collectionOptions.SetWriteConcern(concern)
+	}
+	return collectionOptions
+}
+
+func (s *Datasource) bulkWriteOptions() *options.BulkWriteOptions {
+	w := s.Options.WriteOptions
+	bulkOptions := options.BulkWrite().SetOrdered(opt.Enabled(w.Ordered))
+	if opt.FlagSet(w.BypassDocumentValidation) {
+		bulkOptions.SetBypassDocumentValidation(opt.Enabled(w.BypassDocumentValidation))
+	}
+	return bulkOptions
+}
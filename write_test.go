@@ -0,0 +1,257 @@
+package mongoimport
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	opt "github.com/romnn/configo"
+	"github.com/romnn/deepequal"
+	"github.com/romnn/mongoimport/loaders"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestParseWriteMode(t *testing.T) {
+	for input, expected := range map[string]WriteMode{"": InsertMode, "insert": InsertMode, "Upsert": UpsertMode, "replace": ReplaceMode, "merge": MergeMode} {
+		mode, err := ParseWriteMode(input)
+		if err != nil || mode != expected {
+			t.Errorf("Parsed %q as %q (%v) but expected %q", input, mode, err, expected)
+		}
+	}
+	if _, err := ParseWriteMode("delete"); err == nil {
+		t.Errorf("Expected an error for an unknown write mode")
+	}
+}
+
+func TestWriteModels(t *testing.T) {
+	doc := map[string]interface{}{
+		"name":    "Sally Whittaker",
+		"address": map[string]interface{}{"house": "McCarren House", "room": 312},
+	}
+
+	insert := &Datasource{}
+	if err := insert.validateWriteMode(); err != nil {
+		t.Errorf("Expected the insert mode to be valid without upsert fields: %v", err)
+	}
+	if model, err := insert.writeModel(doc, nil); err != nil {
+		t.Error(err)
+	} else if _, ok := model.(*mongo.InsertOneModel); !ok {
+		t.Errorf("Expected an insert but got %T", model)
+	}
+
+	unkeyed := &Datasource{Options: Options{WriteMode: UpsertMode}}
+	if err := unkeyed.validateWriteMode(); err == nil {
+		t.Errorf("Expected an error for the upsert mode without upsert fields")
+	}
+
+	merge := &Datasource{Options: Options{WriteMode: MergeMode, UpsertFields: []string{"name", "address.room"}}}
+	if err := merge.validateWriteMode(); err != nil {
+		t.Error(err)
+	}
+	model, err := merge.writeModel(doc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, ok := model.(*mongo.UpdateOneModel)
+	if !ok {
+		t.Fatalf("Expected an update but got %T", model)
+	}
+	filter, err := bson.Marshal(update.Filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded bson.D
+	if err := bson.Unmarshal(filter, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := bson.D{{Key: "name", Value: "Sally Whittaker"}, {Key: "address.room", Value: int32(312)}}
+	if equal, err := deepequal.DeepEqual(decoded, expected); !equal {
+		t.Errorf("Filter is %v but expected %v: %v", decoded, expected, err)
+	}
+	if update.Upsert == nil || !*update.Upsert {
+		t.Errorf("Expected the merge to insert missing documents")
+	}
+
+	if _, err := merge.writeModel(map[string]interface{}{"name": "Jeff Smith"}, nil); err == nil {
+		t.Errorf("Expected an error for a document without all upsert fields")
+	}
+
+	// The write filter hook takes precedence over the upsert fields
+	replace := &Datasource{Options: Options{
+		WriteMode:    ReplaceMode,
+		UpsertFields: []string{"name"},
+		WriteFilter: func(doc interface{}) (interface{}, error) {
+			return bson.M{"_id": 1}, nil
+		},
+	}}
+	if model, err := replace.writeModel(doc, nil); err != nil {
+		t.Error(err)
+	} else if r, ok := model.(*mongo.ReplaceOneModel); !ok || r.Upsert != nil {
+		t.Errorf("Expected a replacement without upsert but got %T", model)
+	} else if equal, err := deepequal.DeepEqual(r.Filter, bson.M{"_id": 1}); !equal {
+		t.Errorf("Expected the filter of the hook but got %v: %v", r.Filter, err)
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	for input, expected := range map[string]DuplicatePolicy{"": FailOnDuplicate, "fail": FailOnDuplicate, "skip": SkipDuplicates, "Overwrite": OverwriteDuplicates, "collect": CollectDuplicates} {
+		policy, err := ParseDuplicatePolicy(input)
+		if err != nil || policy != expected {
+			t.Errorf("Parsed %q as %q (%v) but expected %q", input, policy, err, expected)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("ignore"); err == nil {
+		t.Errorf("Expected an error for an unknown duplicate policy")
+	}
+	invalid := &Datasource{Options: Options{OnDuplicate: "ignore"}}
+	if err := invalid.validateWriteMode(); err == nil {
+		t.Errorf("Expected an error for a source with an unknown duplicate policy")
+	}
+
+	message := `E11000 duplicate key error collection: mock.people index: email_1 dup key: { email: "sally@example.com" }`
+	if match := duplicateIndexPattern.FindStringSubmatch(message); match == nil || match[1] != "email_1" {
+		t.Errorf("Expected to find the index email_1 in %q but got %v", message, match)
+	}
+	if !isDuplicateKeyError(11000) || isDuplicateKeyError(121) {
+		t.Errorf("Expected only 11000 to be a duplicate key error")
+	}
+
+	doc := map[string]interface{}{"email": "sally@example.com"}
+	merge := &Datasource{Options: Options{WriteMode: MergeMode, UpsertFields: []string{"email"}}}
+	for _, source := range []*Datasource{{}, merge} {
+		model, err := source.writeModel(doc, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal, err := deepequal.DeepEqual(writeDocument(model), doc); !equal {
+			t.Errorf("Expected the document of %T to be %v: %v", model, doc, err)
+		}
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	writeErr := mongo.WriteError{Index: 1, Code: 121, Message: "Document failed validation"}
+	source := recordSource{record: 41, position: loaders.Position{Line: 43, Offset: 1337}}
+	recordErr := source.error("people.csv", writeErr)
+	expected := "people.csv (record 42, line 43, offset 1337): Document failed validation"
+	if recordErr.Error() != expected {
+		t.Errorf("Error was %q but should be %q", recordErr.Error(), expected)
+	}
+	var unwrapped mongo.WriteError
+	if !errors.As(recordErr, &unwrapped) || unwrapped.Code != 121 {
+		t.Errorf("Expected the record error to wrap the write error")
+	}
+
+	source = recordSource{record: 0, position: loaders.UnknownPosition}
+	if msg := source.error("dump.xml", writeErr).Error(); msg != "dump.xml (record 1): Document failed validation" {
+		t.Errorf("Unexpected error without a known position: %q", msg)
+	}
+}
+
+func TestWriteOptions(t *testing.T) {
+	if concern := (WriteOptions{}).writeConcern(); concern != nil {
+		t.Errorf("Expected the default write concern of the server but got %v", concern)
+	}
+	cases := map[string]interface{}{"majority": "majority", "2": 2, "dc-east": "dc-east"}
+	for w, expected := range cases {
+		concern := (WriteOptions{W: w, Journal: opt.SetFlag(true), WTimeout: time.Second}).writeConcern()
+		if concern == nil || concern.GetW() != expected || !concern.GetJ() || concern.GetWTimeout() != time.Second {
+			t.Errorf("Unexpected write concern %v for w: %q", concern, w)
+		}
+	}
+
+	source := &Datasource{Options: Options{WriteOptions: WriteOptions{Ordered: opt.SetFlag(true), BypassDocumentValidation: opt.SetFlag(true)}}}
+	bulkOptions := source.bulkWriteOptions()
+	if bulkOptions.Ordered == nil || !*bulkOptions.Ordered || bulkOptions.BypassDocumentValidation == nil || !*bulkOptions.BypassDocumentValidation {
+		t.Errorf("Expected ordered writes that bypass validation but got %+v", bulkOptions)
+	}
+	if bulkOptions := (&Datasource{}).bulkWriteOptions(); *bulkOptions.Ordered || bulkOptions.BypassDocumentValidation != nil {
+		t.Errorf("Expected unordered writes with validation by default but got %+v", bulkOptions)
+	}
+
+	for _, invalid := range []WriteOptions{{W: "-1"}, {WTimeout: -time.Second}, {W: "0", Journal: opt.SetFlag(true)}} {
+		if err := (&Datasource{Options: Options{WriteOptions: invalid}}).validateWriteOptions(); err == nil {
+			t.Errorf("Expected an error for the write options %+v", invalid)
+		}
+	}
+}
+
+func TestOrderedDocuments(t *testing.T) {
+	original := bson.D{
+		{Key: "name", Value: "Sally Whittaker"},
+		{Key: "key", Value: bson.D{{Key: "year", Value: 2018}, {Key: "house", Value: "McCarren House"}}},
+		{Key: "rooms", Value: bson.A{bson.D{{Key: "number", Value: 312}, {Key: "floor", Value: 3}}}},
+	}
+	// Hooks received the document as a map and added a field
+	doc := map[string]interface{}{
+		"name":     "Sally Whittaker",
+		"key":      map[string]interface{}{"house": "McCarren House", "year": 2018},
+		"rooms":    []interface{}{map[string]interface{}{"floor": 3, "number": 312}},
+		"imported": true,
+	}
+	model, err := (&Datasource{}).writeModel(doc, original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := bson.Marshal(model.(*mongo.InsertOneModel).Document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := bson.Marshal(append(original, bson.E{Key: "imported", Value: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, expected) {
+		t.Errorf("Expected the fields in the order of %v but got %v", bson.Raw(expected), bson.Raw(written))
+	}
+
+	// Documents of loaders without a field order are written as they are
+	if model, err := (&Datasource{}).writeModel(doc, nil); err != nil {
+		t.Fatal(err)
+	} else if _, ok := model.(*mongo.InsertOneModel).Document.(map[string]interface{}); !ok {
+		t.Errorf("Expected the document to be written unchanged but got %T", model.(*mongo.InsertOneModel).Document)
+	}
+}
+
+func TestOverwriteModel(t *testing.T) {
+	lookups := 0
+	lookupIndex := func(name string) ([]string, error) {
+		lookups++
+		if name != "name_1_address.room_1" {
+			return nil, errors.New("Unknown index")
+		}
+		return []string{"name", "address.room"}, nil
+	}
+	doc := map[string]interface{}{"name": "Sally Whittaker", "address": map[string]interface{}{"room": 312}}
+	message := `E11000 duplicate key error collection: mock.people index: name_1_address.room_1 dup key: { name: "Sally Whittaker", address.room: 312 }`
+	model, err := overwriteModel(mongo.NewInsertOneModel().SetDocument(doc), message, lookupIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replace, ok := model.(*mongo.ReplaceOneModel)
+	if !ok {
+		t.Fatalf("Expected a replace but got %T", model)
+	}
+	filter, err := bson.Marshal(replace.Filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room, err := bson.Raw(filter).LookupErr("address.room"); err != nil || room.Int32() != 312 {
+		t.Errorf("Expected the filter to select the room of the index but got %v", bson.Raw(filter))
+	}
+	if lookups != 1 {
+		t.Errorf("Expected a single index lookup but got %d", lookups)
+	}
+}
+
+func TestDuplicateDocumentsLimit(t *testing.T) {
+	var result PartialResult
+	docs := make([]interface{}, maxDuplicateDocuments-1)
+	result.addDuplicateDocuments(docs...)
+	result.add(PartialResult{DuplicateDocuments: []interface{}{1, 2, 3}})
+	if len(result.DuplicateDocuments) != maxDuplicateDocuments {
+		t.Errorf("Expected at most %d duplicates but got %d", maxDuplicateDocuments, len(result.DuplicateDocuments))
+	}
+}